@@ -1,8 +1,17 @@
 module github.com/mdsol/go-mauth-client/cmd/go_mauth_client
 
-go 1.15
+go 1.19
 
 require (
-	github.com/go-xmlfmt/xmlfmt v0.0.0-20191208150333-d5b6f63a941b // indirect
-	github.com/mdsol/go-mauth-client v0.0.0-20200107105440-cc62405c8dd0 // indirect
+	github.com/go-xmlfmt/xmlfmt v0.0.0-20191208150333-d5b6f63a941b
+	github.com/mdsol/go-mauth-client v0.0.0-20200107105440-cc62405c8dd0
 )
+
+require (
+	github.com/youmark/pkcs8 v0.0.0-20201027041543-1326539a0a0a // indirect
+	golang.org/x/crypto v0.11.0 // indirect
+	software.sslmate.com/src/go-pkcs12 v0.4.0 // indirect
+)
+
+// until a release of the library has the APIs used here
+replace github.com/mdsol/go-mauth-client => ../..
@@ -1,5 +1,4 @@
 // go_mauth_client is a small application using the MAuth Library to make signed calls against Medidata APIs
-//
 package main
 
 import (
@@ -58,6 +57,7 @@ func ProcessConfiguration(content []byte) (mauthApp *go_mauth_client.MAuthApp, e
 	privateKeyFile := context["private_key_file"]
 	privateKeyText := context["private_key_text"]
 	disableV1, err := strconv.ParseBool(context["disable_v1"])
+	signVersionsText := context["sign_versions"]
 	if IsNull(&appUuid) {
 		return nil, errors.New("Need an app_uuid specified")
 	}
@@ -66,13 +66,23 @@ func ProcessConfiguration(content []byte) (mauthApp *go_mauth_client.MAuthApp, e
 	if IsNull(&privateKeyFile) && IsNull(&privateKeyText) {
 		return nil, errors.New("Need a key specified")
 	}
+	// sign_versions (e.g. "v1,v2") takes precedence over disable_v1
+	var signVersions []go_mauth_client.SignVersion
+	if !IsNull(&signVersionsText) {
+		signVersions, err = go_mauth_client.ParseSignVersions(signVersionsText)
+		if err != nil {
+			return nil, err
+		}
+	}
 	// Load from text
 	if IsNull(&privateKeyFile) {
 		// read from the embedded value
-		mauthApp, err = go_mauth_client.LoadMauth(go_mauth_client.MAuthOptions{appUuid, privateKeyText, disableV1})
+		mauthApp, err = go_mauth_client.LoadMauth(go_mauth_client.MAuthOptions{AppId: appUuid, PrivateKey: privateKeyText, DisableV1: disableV1,
+			SignVersions: signVersions})
 	} else {
 		// load the key from a file
-		mauthApp, err = go_mauth_client.LoadMauth(go_mauth_client.MAuthOptions{appUuid, privateKeyFile, disableV1})
+		mauthApp, err = go_mauth_client.LoadMauth(go_mauth_client.MAuthOptions{AppId: appUuid, PrivateKey: privateKeyFile, DisableV1: disableV1,
+			SignVersions: signVersions})
 	}
 	return
 }
@@ -105,6 +115,8 @@ func main() {
 	appUuid := flag.String("app-uuid", "", "Specify the App UUID")
 	// disableV1 specifies if V1 signing should occurr
 	disableV1 := flag.Bool("disableV1", false, "Specify if V1 signing should be disabled")
+	// signVersionsText is the comma separated protocol versions to sign with, taking precedence over disableV1
+	signVersionsText := flag.String("sign-versions", "", "Specify the protocol versions to sign with (e.g. v2 or v1,v2)")
 	// action is the HTTP Verb to use on the URL
 	action := flag.String("method", "GET", "Specify the method (GET, POST, PUT, DELETE)")
 	// data is the data to be POST or PUT
@@ -144,7 +156,16 @@ func main() {
 		}
 	} else {
 		var err error
-		mauthApp, err = go_mauth_client.LoadMauth(go_mauth_client.MAuthOptions{*appUuid, *keyFile, *disableV1})
+		var signVersions []go_mauth_client.SignVersion
+		if !IsNull(signVersionsText) {
+			signVersions, err = go_mauth_client.ParseSignVersions(*signVersionsText)
+			if err != nil {
+				log.Fatal("Error loading configuration: ", err)
+				os.Exit(1)
+			}
+		}
+		mauthApp, err = go_mauth_client.LoadMauth(go_mauth_client.MAuthOptions{AppId: *appUuid, PrivateKey: *keyFile, DisableV1: *disableV1,
+			SignVersions: signVersions})
 		if err != nil {
 			log.Fatal("Error loading configuration: ", err)
 			os.Exit(1)
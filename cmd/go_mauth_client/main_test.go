@@ -4,6 +4,8 @@ import (
 	"io/ioutil"
 	"strings"
 	"testing"
+
+	go_mauth_client "github.com/mdsol/go-mauth-client"
 )
 
 // Confirm the IsNull function identifies an empty string and vice versa
@@ -51,7 +53,7 @@ func TestLoadMAuthConfig(t *testing.T) {
 	if client == nil {
 		t.Error("Expected existing file to return not nil")
 	}
-	if client.AppId != "11111111-2222-4105-b42e-88888888888" {
+	if client.AppId != "11111111-2222-4105-b42e-888888888888" {
 		t.Error("Incorrect APP ID")
 	}
 }
@@ -70,12 +72,12 @@ func TestProcessConfiguration(t *testing.T) {
 	if err == nil {
 		t.Error("Expected failure with no app_uuid")
 	}
-	testJson = "{\"app_uuid\":\"11111111-2222-4105-b42e-88888888888\"}"
+	testJson = "{\"app_uuid\":\"11111111-2222-4105-b42e-888888888888\"}"
 	_, err = ProcessConfiguration([]byte(testJson))
 	if err == nil {
 		t.Error("Expected failure with no private key details")
 	}
-	testJson = "{\"app_uuid\":\"11111111-2222-4105-b42e-88888888888\",\"private_key_file\":\"test/private_key.pem\",\"disable_v1\":\"false\"}"
+	testJson = "{\"app_uuid\":\"11111111-2222-4105-b42e-888888888888\",\"private_key_file\":\"test/private_key.pem\",\"disable_v1\":\"false\"}"
 	_, err = ProcessConfiguration([]byte(testJson))
 	if err != nil {
 		t.Error("Expected success with app_uuid and private_key_file")
@@ -85,9 +87,28 @@ func TestProcessConfiguration(t *testing.T) {
 	// escape the newlines
 	key_content := strings.Replace(key_text, "\n", "\\n", -1)
 	key_content = strings.Replace(key_content, "\r", "\\r", -1)
-	testJson = "{\"app_uuid\":\"11111111-2222-4105-b42e-88888888888\",\"private_key_text\":\"" + key_content + "\",\"disable_v1\":\"false\"}"
+	testJson = "{\"app_uuid\":\"11111111-2222-4105-b42e-888888888888\",\"private_key_text\":\"" + key_content + "\",\"disable_v1\":\"false\"}"
 	_, err = ProcessConfiguration([]byte(testJson))
 	if err != nil {
 		t.Error("Expected success with app_uuid and private_key_text")
 	}
+	testJson = "{\"app_uuid\":\"11111111-2222-4105-b42e-888888888888\",\"private_key_file\":\"test/private_key.pem\",\"disable_v1\":\"true\"}"
+	mauthApp, err := ProcessConfiguration([]byte(testJson))
+	if err != nil {
+		t.Error("Expected success with disable_v1")
+	} else if len(mauthApp.SignVersions) != 1 || mauthApp.SignVersions[0] != go_mauth_client.SignV2 {
+		t.Error("Expected disable_v1 to sign with V2 only, got ", mauthApp.SignVersions)
+	}
+	testJson = "{\"app_uuid\":\"11111111-2222-4105-b42e-888888888888\",\"private_key_file\":\"test/private_key.pem\",\"sign_versions\":\"v1\"}"
+	mauthApp, err = ProcessConfiguration([]byte(testJson))
+	if err != nil {
+		t.Error("Expected success with sign_versions")
+	} else if len(mauthApp.SignVersions) != 1 || mauthApp.SignVersions[0] != go_mauth_client.SignV1 {
+		t.Error("Expected sign_versions to sign with V1 only, got ", mauthApp.SignVersions)
+	}
+	testJson = "{\"app_uuid\":\"11111111-2222-4105-b42e-888888888888\",\"private_key_file\":\"test/private_key.pem\",\"sign_versions\":\"v3\"}"
+	_, err = ProcessConfiguration([]byte(testJson))
+	if err == nil {
+		t.Error("Expected failure with an unknown sign_versions entry")
+	}
 }
@@ -13,12 +13,11 @@ import (
 //   MAUTH_PRIVATE_KEY - the Private Key content
 func LoadApp() (mauthApp *go_mauth_client.MAuthApp, err error) {
 	appUUID := os.Getenv("MAUTH_APP_UUID")
-	privateKeyString := os.Getenv("MAUTH_PRIVATE_KEY")
 
 	// load the configuration from the environment
-	mauthApp, err = go_mauth_client.LoadMauth(go_mauth_client.MAuthOptions{appUUID,
-		privateKeyString,
-		false})
+	mauthApp, err = go_mauth_client.LoadMauth(go_mauth_client.MAuthOptions{AppId: appUUID,
+		KeySource: go_mauth_client.KeyFromEnv("MAUTH_PRIVATE_KEY"),
+		DisableV1: false})
 	if err != nil {
 		log.Fatal("Unable: to load client configuration; "+
 			"did you define MAUTH_APP_UUID and "+
@@ -1,3 +1,10 @@
 module github.com/mdsol/go-mauth-client
 
-go 1.15
+go 1.19
+
+require (
+	github.com/youmark/pkcs8 v0.0.0-20201027041543-1326539a0a0a
+	software.sslmate.com/src/go-pkcs12 v0.4.0
+)
+
+require golang.org/x/crypto v0.11.0 // indirect
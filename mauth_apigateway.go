@@ -0,0 +1,102 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+)
+
+/*
+Signing and verification for AWS API Gateway Lambda proxy integration events
+*/
+
+// APIGatewayProxyRequest holds the fields of an API Gateway Lambda proxy event that take part in MAuth.
+// The JSON names match the event, so it can be unmarshalled directly from the Lambda payload
+// or copied from an events.APIGatewayProxyRequest.
+type APIGatewayProxyRequest struct {
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	Body                            string              `json:"body"`
+	IsBase64Encoded                 bool                `json:"isBase64Encoded"`
+}
+
+// target rebuilds the request URL from the path and query parameters; the multi-value
+// parameters are preferred as they hold every value for a repeated key
+func (event *APIGatewayProxyRequest) target() *url.URL {
+	query := url.Values{}
+	if len(event.MultiValueQueryStringParameters) > 0 {
+		for key, values := range event.MultiValueQueryStringParameters {
+			for _, value := range values {
+				query.Add(key, value)
+			}
+		}
+	} else {
+		for key, value := range event.QueryStringParameters {
+			query.Set(key, value)
+		}
+	}
+	return &url.URL{Path: event.Path, RawQuery: query.Encode()}
+}
+
+// body returns the request body as it was sent, decoding it if API Gateway base64 encoded it
+func (event *APIGatewayProxyRequest) body() ([]byte, error) {
+	if !event.IsBase64Encoded {
+		return []byte(event.Body), nil
+	}
+	return base64.StdEncoding.DecodeString(event.Body)
+}
+
+// header merges the single and multi-value headers into a http.Header, which handles the case
+// insensitivity of header names
+func (event *APIGatewayProxyRequest) header() http.Header {
+	header := http.Header{}
+	for name, values := range event.MultiValueHeaders {
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+	for name, value := range event.Headers {
+		if _, exists := header[http.CanonicalHeaderKey(name)]; !exists {
+			header.Set(name, value)
+		}
+	}
+	return header
+}
+
+// SignAPIGatewayRequest adds the MAuth headers to an API Gateway proxy event, for use when
+// testing Lambda functions or invoking them directly
+func (mauthApp *MAuthApp) SignAPIGatewayRequest(event *APIGatewayProxyRequest) error {
+	body, err := event.body()
+	if err != nil {
+		return err
+	}
+	madeHeaders, err := mauthApp.makeBodyHeaders(event.HTTPMethod, event.target(), bytes.NewReader(body),
+		mauthApp.epoch())
+	if err != nil {
+		return err
+	}
+	if event.Headers == nil {
+		event.Headers = make(map[string]string)
+	}
+	for header, value := range madeHeaders {
+		event.Headers[header] = value
+		if event.MultiValueHeaders != nil {
+			event.MultiValueHeaders[header] = []string{value}
+		}
+	}
+	return nil
+}
+
+// VerifyAPIGatewayRequest authenticates an API Gateway proxy event, returning the App UUID that signed it
+func (verifier *MAuthVerifier) VerifyAPIGatewayRequest(event *APIGatewayProxyRequest) (appId string, err error) {
+	body, err := event.body()
+	if err != nil {
+		return "", err
+	}
+	return verifier.verify(event.HTTPMethod, event.target(), body, event.header())
+}
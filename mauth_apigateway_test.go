@@ -0,0 +1,98 @@
+package go_mauth_client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// loadAPIGatewayEvent reads the sample proxy event
+func loadAPIGatewayEvent(t *testing.T) *APIGatewayProxyRequest {
+	content, err := ioutil.ReadFile(filepath.Join("test", "apigateway_event.json"))
+	if err != nil {
+		t.Fatal("Unable to read event fixture: ", err)
+	}
+	var event APIGatewayProxyRequest
+	if err := json.Unmarshal(content, &event); err != nil {
+		t.Fatal("Unable to parse event fixture: ", err)
+	}
+	return &event
+}
+
+func TestAPIGatewayProxyRequest_body(t *testing.T) {
+	event := loadAPIGatewayEvent(t)
+	body, err := event.body()
+	if err != nil {
+		t.Error("Error decoding body: ", err)
+	}
+	if string(body) != `{"subject":"001-001"}` {
+		t.Error("Unexpected body: ", string(body))
+	}
+	event.Body = "!!!"
+	if _, err := event.body(); err == nil {
+		t.Error("Expected error decoding an invalid base64 body")
+	}
+}
+
+func TestAPIGatewayProxyRequest_target(t *testing.T) {
+	event := loadAPIGatewayEvent(t)
+	expected := "/studies/9fd7d43b-4f50-4a6a-a6c2-2a8b3f1a1a11/subjects?site=101&status=screening&status=active"
+	if actual := event.target().RequestURI(); actual != expected {
+		t.Error("Unexpected request URI: ", actual)
+	}
+	event.MultiValueQueryStringParameters = nil
+	expected = "/studies/9fd7d43b-4f50-4a6a-a6c2-2a8b3f1a1a11/subjects?site=101&status=active"
+	if actual := event.target().RequestURI(); actual != expected {
+		t.Error("Unexpected request URI: ", actual)
+	}
+}
+
+func TestMAuthApp_SignAPIGatewayRequest(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	event := loadAPIGatewayEvent(t)
+	if err := mauthApp.SignAPIGatewayRequest(event); err != nil {
+		t.Error("Error signing event: ", err)
+	}
+	if event.Headers["MCC-Authentication"] == "" || event.Headers["X-MWS-Authentication"] == "" {
+		t.Error("Expected MAuth headers not present")
+	}
+	if len(event.MultiValueHeaders["MCC-Time"]) != 1 {
+		t.Error("Expected MAuth headers in the multi-value headers")
+	}
+	appId, err := testVerifier(mauthApp).VerifyAPIGatewayRequest(event)
+	if err != nil {
+		t.Error("Event did not verify: ", err)
+	}
+	if appId != app_id {
+		t.Error("Unexpected App UUID ", appId)
+	}
+}
+
+func TestMAuthVerifier_VerifyAPIGatewayRequestRoundTrip(t *testing.T) {
+	// a signed event survives being serialised as the Lambda payload
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: true})
+	event := loadAPIGatewayEvent(t)
+	event.MultiValueHeaders = nil
+	_ = mauthApp.SignAPIGatewayRequest(event)
+	payload, _ := json.Marshal(event)
+	var received APIGatewayProxyRequest
+	_ = json.Unmarshal(payload, &received)
+	if _, err := testVerifier(mauthApp).VerifyAPIGatewayRequest(&received); err != nil {
+		t.Error("Event did not verify: ", err)
+	}
+}
+
+func TestMAuthVerifier_VerifyAPIGatewayRequestTampered(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	event := loadAPIGatewayEvent(t)
+	_ = mauthApp.SignAPIGatewayRequest(event)
+	event.MultiValueQueryStringParameters["site"] = []string{"102"}
+	if _, err := testVerifier(mauthApp).VerifyAPIGatewayRequest(event); err != ErrInvalidSignature {
+		t.Error("Expected ErrInvalidSignature for a changed query, got ", err)
+	}
+	event = loadAPIGatewayEvent(t)
+	if _, err := testVerifier(mauthApp).VerifyAPIGatewayRequest(event); err != ErrMissingAuthentication {
+		t.Error("Expected ErrMissingAuthentication for an unsigned event, got ", err)
+	}
+}
@@ -2,11 +2,10 @@ package go_mauth_client
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"errors"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -17,99 +16,179 @@ import (
 type MAuthApp struct {
 	AppId         string
 	RsaPrivateKey *rsa.PrivateKey
-	DisableV1     bool
+	// DisableV1 turns off V1 signing, whatever SignVersions holds
+	DisableV1 bool
+	// SignVersions are the protocol versions requests are signed with
+	SignVersions []SignVersion
+	// Signer, when set, is used for signing in place of RsaPrivateKey so the key can be held
+	// in a KMS or HSM; see SignString for what it must support
+	Signer crypto.Signer
+	// Clock, when set, supplies the signing time in place of time.Now; with FixedClock, signing the
+	// same request again gives the same headers
+	Clock func() time.Time
+	// Rand, when set, is the randomness passed to the signer and used for multipart boundaries and
+	// WebSocket keys, in place of crypto/rand.Reader
+	Rand io.Reader
+	// SkewCorrection, when set, is added to the signing time; MAuthClient and MAuthTransport
+	// update it from the server time of each response
+	SkewCorrection *ClockSkew
 }
 
 type MAuthOptions struct {
 	AppId      string
 	PrivateKey string
 	DisableV1  bool
+	// SignVersions, when set, are the protocol versions to sign with, taking precedence over
+	// DisableV1 and the MAUTH_SIGN_VERSIONS environment variable
+	SignVersions []SignVersion
+	// Passphrase decrypts an encrypted PEM private key or a PKCS#12 bundle
+	Passphrase string
+	// KeySource, when set, supplies the private key in place of PrivateKey
+	KeySource KeySource
+	// MinKeySize is the smallest key size accepted, in bits; DefaultMinKeySize if zero
+	MinKeySize int
+	// PublicKey, when set, is the PEM public key registered for the App, which the private key must match
+	PublicKey string
+	// CorrectClockSkew learns the offset of the local clock from server time and applies it when signing;
+	// the time is only taken from responses signed by a server whose public key ServerKeys has
+	CorrectClockSkew bool
+	// ServerKeys has the public keys of the servers whose signed responses CorrectClockSkew learns from
+	ServerKeys PublicKeyProvider
+	// ReloadKey, when set, reloads the private key from its source as it is rotated: the App signs
+	// through a *ReloadingKey as its Signer, with RsaPrivateKey left nil.  Passphrase and MinKeySize
+	// apply when not set in the ReloadOptions.  Call MAuthApp.Close to stop reloading.
+	ReloadKey *ReloadOptions
 }
 
-// LoadMauth loads the configuration  when the private key content is in a file
+// LoadMauth loads the configuration  when the private key content is in a file.
+// The key may be PKCS#1 or PKCS#8 PEM, either of which may be encrypted, or a PKCS#12 bundle;
+// encrypted keys need the Passphrase option.
+// PrivateKey is treated as a path, or as the PEM content when it holds a PEM block; use
+// KeySource to say explicitly where the key comes from.
 func LoadMauth(options MAuthOptions) (*MAuthApp, error) {
-	// Create the MAuthApp struct
-	keyFileContent, err := ioutil.ReadFile(options.PrivateKey)
+	source := options.KeySource
+	if source == nil {
+		source = KeyFromFile(options.PrivateKey)
+		if strings.Contains(options.PrivateKey, "-----BEGIN ") {
+			source = KeyFromPEM(options.PrivateKey)
+		}
+	}
+	if err := validateAppId(options.AppId); err != nil {
+		return nil, err
+	}
+	signVersions, err := resolveSignVersions(options)
 	if err != nil {
-		keyFileContent = []byte(options.PrivateKey)
+		return nil, err
 	}
-
-	block, _ := pem.Decode(keyFileContent)
-	if block == nil {
-		return nil, errors.New("Unable to extract PEM content")
+	if options.CorrectClockSkew && options.ServerKeys == nil {
+		return nil, errors.New("CorrectClockSkew needs ServerKeys to verify the responses it learns from")
 	}
-
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if options.ReloadKey != nil {
+		return loadReloadingMauth(source, options, signVersions)
+	}
+	privateKey, err := loadKey(source, options.Passphrase)
 	if err != nil {
 		return nil, err
 	}
+	if err := validateKey(privateKey, options); err != nil {
+		return nil, err
+	}
 
 	app := MAuthApp{AppId: options.AppId,
 		RsaPrivateKey: privateKey,
-		DisableV1:     options.DisableV1}
+		DisableV1:     !hasSignVersion(signVersions, SignV1),
+		SignVersions:  signVersions}
+	if options.CorrectClockSkew {
+		app.SkewCorrection = NewClockSkew(options.ServerKeys)
+	}
 	return &app, nil
 }
 
-// makeRequest formulates the message, including the MAuth Headers and returns a http.Request, ready to send
-func (mauthApp *MAuthApp) makeRequest(method string, rawurl string, body string,
-	extraHeaders map[string][]string) (req *http.Request, err error) {
-
-	// create a new request object
-	req, err = http.NewRequest(method, rawurl, bytes.NewBuffer([]byte(body)))
+// loadReloadingMauth loads an App which signs through a ReloadingKey on source
+func loadReloadingMauth(source KeySource, options MAuthOptions, signVersions []SignVersion) (*MAuthApp, error) {
+	reloadOptions := *options.ReloadKey
+	if reloadOptions.Passphrase == "" {
+		reloadOptions.Passphrase = options.Passphrase
+	}
+	if reloadOptions.MinKeySize == 0 {
+		reloadOptions.MinKeySize = options.MinKeySize
+	}
+	reloading, err := NewReloadingKey(source, reloadOptions)
 	if err != nil {
 		return nil, err
 	}
-
-	// Use the url.URL to assist with path management
-	url2, err := url.Parse(rawurl)
-	if err != nil {
+	if err := validateKey(reloading.PrivateKey(), options); err != nil {
+		_ = reloading.Close()
 		return nil, err
 	}
-	// this needs to persist
-	secondsSinceEpoch := time.Now().Unix()
-
-	if !mauthApp.DisableV1 {
-		// build the MWS string
-		stringToSign := MakeSignatureString(mauthApp, method, url2.Path, body, secondsSinceEpoch)
-
-		// Sign the string
-		signedString, err := SignString(mauthApp, stringToSign)
-		if err != nil {
-			return nil, err
-		}
+	app := MAuthApp{AppId: options.AppId,
+		Signer:       reloading,
+		DisableV1:    !hasSignVersion(signVersions, SignV1),
+		SignVersions: signVersions}
+	if options.CorrectClockSkew {
+		app.SkewCorrection = NewClockSkew(options.ServerKeys)
+	}
+	return &app, nil
+}
 
-		// take everything and build the structure of the MAuth Headers
-		madeHeaders := MakeAuthenticationHeaders(mauthApp, signedString, secondsSinceEpoch)
-		for header, value := range madeHeaders {
-			req.Header.Set(header, value)
-		}
+// signer returns the crypto.Signer used to sign for the App
+func (mauthApp *MAuthApp) signer() (crypto.Signer, error) {
+	if mauthApp.Signer != nil {
+		return mauthApp.Signer, nil
 	}
+	if mauthApp.RsaPrivateKey == nil {
+		return nil, errors.New("No private key or signer configured")
+	}
+	precompute(mauthApp.RsaPrivateKey)
+	return mauthApp.RsaPrivateKey, nil
+}
 
-	// build the MCC string
-	stringToSignV2 := MakeSignatureStringV2(mauthApp, method, url2.RequestURI(), body, secondsSinceEpoch)
+// makeRequest formulates the message, including the MAuth Headers and returns a http.Request, ready to send
+func (mauthApp *MAuthApp) makeRequest(method string, rawurl string, body string,
+	extraHeaders map[string][]string) (req *http.Request, err error) {
+	// Detect JSON, send appropriate Content-Type if detected
+	contentType := ""
+	if isJSON(body) == true {
+		contentType = "application/json"
+	}
+	return mauthApp.makeStreamingRequest(method, rawurl, strings.NewReader(body), contentType, extraHeaders)
+}
 
-	signedStringV2, err := SignStringV2(mauthApp, stringToSignV2)
+// SignRequest adds the MAuth headers to a request that has been built elsewhere, so it can be used
+// with any library that hands over a request to decorate.  The body is read to be signed and then
+// replaced, so the request can still be sent.
+func (mauthApp *MAuthApp) SignRequest(req *http.Request) error {
+	body, err := readAndRestoreBody(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	madeHeadersV2 := MakeAuthenticationHeadersV2(mauthApp, signedStringV2, secondsSinceEpoch)
-	for header, value := range madeHeadersV2 {
+	madeHeaders, err := mauthApp.makeBodyHeaders(req.Method, req.URL, bytes.NewReader(body), mauthApp.epoch())
+	if err != nil {
+		return err
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	for header, value := range madeHeaders {
 		req.Header.Set(header, value)
 	}
+	return nil
+}
 
-	// Detect JSON, send appropriate Content-Type if detected
-	if isJSON(body) == true {
-		req.Header.Set("Content-Type", "application/json")
-	}
-	// Merge in any extra headers
-	for header, values := range extraHeaders {
-		for _, value := range values {
-			req.Header.Add(header, value)
-		}
+// makeAuthenticationHeaders signs the request details and returns the MAuth headers for the
+// enabled protocol versions
+func (mauthApp *MAuthApp) makeAuthenticationHeaders(method string, target *url.URL, body string,
+	secondsSinceEpoch int64) (map[string]string, error) {
+	return mauthApp.makeBodyHeaders(method, target, strings.NewReader(body), secondsSinceEpoch)
+}
+
+// makeBodyHeaders signs the request details with the body read from a reader, so a body already
+// held as bytes needn't be copied to a string
+func (mauthApp *MAuthApp) makeBodyHeaders(method string, target *url.URL, body io.Reader,
+	secondsSinceEpoch int64) (map[string]string, error) {
+	digests, err := mauthApp.hashBody(method, target, body, secondsSinceEpoch)
+	if err != nil {
+		return nil, err
 	}
-	// Add the User-Agent using the Client Version
-	req.Header.Set("User-Agent",
-		strings.Join([]string{"go-mauth-client", GetVersion()}, "/"))
-	return req, nil
+	return mauthApp.makeDigestHeaders(method, target, digests, secondsSinceEpoch)
 }
@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
 func TestLoadMauth(t *testing.T) {
-	mauth, err := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauth, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	if err != nil {
 		t.Error("Error creating the MAuth Struct")
 	}
@@ -22,21 +24,21 @@ func TestLoadMauth(t *testing.T) {
 }
 
 func TestLoadMauthMissingFile(t *testing.T) {
-	_, err := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "banana.pem"), false})
+	_, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "banana.pem"), DisableV1: false})
 	if err == nil {
 		t.Error("Expected Error creating the MAuth Struct")
 	}
 }
 
 func TestLoadMauthNotKey(t *testing.T) {
-	_, err := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "junk.pem"), false})
+	_, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "junk.pem"), DisableV1: false})
 	if err == nil {
 		t.Error("Expected Error loading an empty Private Key file")
 	}
 }
 
 func TestLoadMauthInvalidKey(t *testing.T) {
-	_, err := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "invalid.pem"), false})
+	_, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "invalid.pem"), DisableV1: false})
 	if err == nil {
 		t.Error("Expected Error loading an invalid Private Key")
 	}
@@ -44,7 +46,7 @@ func TestLoadMauthInvalidKey(t *testing.T) {
 
 func TestLoadMauthFromString(t *testing.T) {
 	keyContent, _ := ioutil.ReadFile(filepath.Join("test", "private_key.pem"))
-	mauth, err := LoadMauth(MAuthOptions{app_id, string(keyContent), false})
+	mauth, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: string(keyContent), DisableV1: false})
 	if err != nil {
 		t.Error("Error creating the MAuth Struct")
 	}
@@ -58,7 +60,7 @@ func TestLoadMauthFromString(t *testing.T) {
 
 func TestLoadMauthFromStringNotKey(t *testing.T) {
 	keyContent, _ := ioutil.ReadFile(filepath.Join("test", "junk.pem"))
-	_, err := LoadMauth(MAuthOptions{app_id, string(keyContent), false})
+	_, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: string(keyContent), DisableV1: false})
 	if err == nil {
 		t.Error("Expected Error loading an empty Private Key file")
 	}
@@ -66,7 +68,7 @@ func TestLoadMauthFromStringNotKey(t *testing.T) {
 
 func TestLoadMauthFromStringInvalidKey(t *testing.T) {
 	keyContent, _ := ioutil.ReadFile(filepath.Join("test", "invalid.pem"))
-	_, err := LoadMauth(MAuthOptions{app_id, string(keyContent), false})
+	_, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: string(keyContent), DisableV1: false})
 	if err == nil {
 		t.Error("Expected Error loading an invalid Private Key")
 	}
@@ -80,7 +82,7 @@ func ExampleLoadMauth() {
 	var keyPath = filepath.Join("test", "private_key.pem")
 	// create a MAuth client
 	var client *MAuthApp
-	client, err := LoadMauth(MAuthOptions{appUUID, keyPath, false})
+	client, err := LoadMauth(MAuthOptions{AppId: appUUID, PrivateKey: keyPath, DisableV1: false})
 	if err != nil {
 		log.Fatal("Unable to create client: ", err)
 	}
@@ -88,7 +90,7 @@ func ExampleLoadMauth() {
 }
 
 // Example for creating client where the Private Key is provided as a String
-func ExampleLoadMauthFromString() {
+func ExampleLoadMauth_fromString() {
 	// given an APP_UUID
 	var appUUID = "7D0B2A90-0825-4AD8-9C1F-E9851795D428"
 	// and the content of the Private Key
@@ -96,7 +98,7 @@ func ExampleLoadMauthFromString() {
 	keyString, _ = ioutil.ReadFile(filepath.Join("test", "private_key.pem"))
 	// create a MAuth client
 	var client *MAuthApp
-	client, err := LoadMauth(MAuthOptions{appUUID, string(keyString), false})
+	client, err := LoadMauth(MAuthOptions{AppId: appUUID, PrivateKey: string(keyString), DisableV1: false})
 	if err != nil {
 		log.Fatal("Unable to create client: ", err)
 	}
@@ -104,7 +106,7 @@ func ExampleLoadMauthFromString() {
 }
 
 func TestMAuthApp_makeRequestUserAgent(t *testing.T) {
-	mauth, err := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauth, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	if err != nil {
 		t.Error("Error creating the MAuth Struct")
 	}
@@ -119,7 +121,7 @@ func TestMAuthApp_makeRequestUserAgent(t *testing.T) {
 }
 
 func TestMAuthApp_makeRequestEmpty(t *testing.T) {
-	mauth, err := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauth, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	if err != nil {
 		t.Error("Error creating the MAuth Struct")
 	}
@@ -133,7 +135,7 @@ func TestMAuthApp_makeRequestEmpty(t *testing.T) {
 }
 
 func TestMAuthApp_makeRequestJSON(t *testing.T) {
-	mauth, err := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauth, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	if err != nil {
 		t.Error("Error creating the MAuth Struct")
 	}
@@ -146,7 +148,7 @@ func TestMAuthApp_makeRequestJSON(t *testing.T) {
 }
 
 func TestMAuthApp_makeRequestInvalidURL(t *testing.T) {
-	mauth, err := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauth, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	if err != nil {
 		t.Error("Error creating the MAuth Struct")
 	}
@@ -156,3 +158,56 @@ func TestMAuthApp_makeRequestInvalidURL(t *testing.T) {
 		t.Error("Expected Error with non-sensical URL")
 	}
 }
+
+func TestMAuthApp_SignRequest(t *testing.T) {
+	mauth, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	request, _ := http.NewRequest("PUT", "https://innovate.mdsol.com/api/v2/users.json?until=2100",
+		strings.NewReader(`{"uuid":"1234-1234"}`))
+	request.Header.Set("Content-Type", "application/json")
+	err := mauth.SignRequest(request)
+	if err != nil {
+		t.Error("Error signing request: ", err)
+	}
+	if !hasMWSHeader(request) || !hasMCCHeader(request) {
+		t.Error("Expected MAuth headers not present")
+	}
+	if request.Header.Get("Content-Type") != "application/json" {
+		t.Error("Existing headers should be kept")
+	}
+	if _, err := testVerifier(mauth).VerifyRequest(request); err != nil {
+		t.Error("Signed request did not verify: ", err)
+	}
+	// the body can still be sent
+	body, _ := ioutil.ReadAll(request.Body)
+	if string(body) != `{"uuid":"1234-1234"}` {
+		t.Error("Request body was not restored: ", string(body))
+	}
+}
+
+func TestMAuthApp_SignRequestDisabledV1(t *testing.T) {
+	mauth, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: true})
+	request, _ := http.NewRequest("GET", "https://innovate.mdsol.com/api/v2/users.json", nil)
+	if err := mauth.SignRequest(request); err != nil {
+		t.Error("Error signing request: ", err)
+	}
+	if hasMWSHeader(request) {
+		t.Error("Unexpected MWS header present")
+	}
+	if !hasMCCHeader(request) {
+		t.Error("Expected MCC header not present")
+	}
+}
+
+// Example of signing a request built by another library
+func ExampleMAuthApp_SignRequest() {
+	var appUUID = "7D0B2A90-0825-4AD8-9C1F-E9851795D428"
+	mAuthApp, err := LoadMauth(MAuthOptions{AppId: appUUID, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	if err != nil {
+		log.Fatal("Unable to create mAuthApp: ", err)
+	}
+	request, _ := http.NewRequest("GET", "https://innovate.imedidata.com/api/v2/users.json", nil)
+	if err := mAuthApp.SignRequest(request); err != nil {
+		log.Fatal("Unable to sign request: ", err)
+	}
+	println("Signed with", request.Header.Get("MCC-Authentication"))
+}
@@ -0,0 +1,95 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+// benchmarkApp loads the test App signing with the given versions
+func benchmarkApp(b *testing.B, versions ...SignVersion) *MAuthApp {
+	mauthApp, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"),
+		SignVersions: versions})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return mauthApp
+}
+
+func benchmarkSignRequest(b *testing.B, mauthApp *MAuthApp, body []byte) {
+	req, _ := http.NewRequest("POST", "https://innovate.mdsol.com/api/v2/users.json?until=2100&page=2", nil)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req.Body = http.NoBody
+		req.ContentLength = int64(len(body))
+		if len(body) > 0 {
+			req.Body = readCloser{bytes.NewReader(body)}
+		}
+		if err := mauthApp.SignRequest(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// readCloser gives a reader a no-op Close for use as a request body
+type readCloser struct {
+	*bytes.Reader
+}
+
+func (readCloser) Close() error { return nil }
+
+func BenchmarkSignRequestV1V2(b *testing.B) {
+	benchmarkSignRequest(b, benchmarkApp(b, SignV1, SignV2), []byte(`{"user": "test"}`))
+}
+
+func BenchmarkSignRequestV2(b *testing.B) {
+	benchmarkSignRequest(b, benchmarkApp(b, SignV2), []byte(`{"user": "test"}`))
+}
+
+func BenchmarkSignRequestV2LargeBody(b *testing.B) {
+	benchmarkSignRequest(b, benchmarkApp(b, SignV2), bytes.Repeat([]byte("0123456789abcdef"), 64*1024))
+}
+
+func BenchmarkMakeAuthenticationHeadersV1V2(b *testing.B) {
+	mauthApp := benchmarkApp(b, SignV1, SignV2)
+	target, _ := url.Parse("https://innovate.mdsol.com/api/v2/users.json?until=2100&page=2")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := mauthApp.makeAuthenticationHeaders("GET", target, "", 1500000000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHashBody(b *testing.B) {
+	mauthApp := benchmarkApp(b, SignV1, SignV2)
+	target, _ := url.Parse("https://innovate.mdsol.com/api/v2/users.json")
+	body := bytes.Repeat([]byte("0123456789abcdef"), 64)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := mauthApp.hashBody("POST", target, bytes.NewReader(body), 1500000000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifyRequest(b *testing.B) {
+	mauthApp := benchmarkApp(b, SignV2)
+	verifier := testVerifier(mauthApp)
+	body := []byte(`{"user": "test"}`)
+	req, _ := http.NewRequest("POST", "https://innovate.mdsol.com/api/v2/users.json", readCloser{bytes.NewReader(body)})
+	_ = mauthApp.SignRequest(req)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req.Body = readCloser{bytes.NewReader(body)}
+		if _, err := verifier.VerifyRequest(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package go_mauth_client
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+Signing with a body hash supplied by the caller, for uploads whose SHA-512 is already known
+(e.g. from object storage metadata) so the body need not be read to sign it.  V1 can't be signed
+this way, as it signs the raw body.
+*/
+
+var (
+	// ErrV1RequiresBody is returned when signing with a body hash for an App which only signs with V1
+	ErrV1RequiresBody = errors.New("MAuth V1 signs the raw body and can't be signed with a body hash")
+	// ErrInvalidBodyHash is returned when a body hash is not a hex encoded SHA-512
+	ErrInvalidBodyHash = errors.New("Body hash must be a hex encoded SHA-512")
+)
+
+// normalizeBodyHash checks the body hash is a hex encoded SHA-512, returning it in lower case
+// as the V2 string to sign has it
+func normalizeBodyHash(bodyHash string) (string, error) {
+	decoded, err := hex.DecodeString(bodyHash)
+	if err != nil || len(decoded) != 64 {
+		return "", ErrInvalidBodyHash
+	}
+	return strings.ToLower(bodyHash), nil
+}
+
+// makeBodyHashHeaders signs the request details with the body hash, returning the V2 headers and
+// those of any registered schemes.  V1 is left out, whatever the App's versions, as it can't be
+// signed without the body.
+func (mauthApp *MAuthApp) makeBodyHashHeaders(method string, target *url.URL, bodyHash string,
+	secondsSinceEpoch int64) (map[string]string, error) {
+	versions := []SignVersion{}
+	if mauthApp.signsWith(SignV2) {
+		versions = append(versions, SignV2)
+	}
+	for _, version := range mauthApp.SignVersions {
+		if version != SignV1 && version != SignV2 {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, ErrV1RequiresBody
+	}
+	bodyHash, err := normalizeBodyHash(bodyHash)
+	if err != nil {
+		return nil, err
+	}
+	return mauthApp.withSignVersions(versions).makeDigestHeaders(method, target,
+		&bodyDigests{body: bodyHash}, secondsSinceEpoch)
+}
+
+// SignRequestWithBodyHash adds the V2 MAuth headers to a request, signing with the hex encoded SHA-512
+// of its body in place of reading the body.  No V1 headers are added.
+func (mauthApp *MAuthApp) SignRequestWithBodyHash(req *http.Request, bodyHash string) error {
+	madeHeaders, err := mauthApp.makeBodyHashHeaders(req.Method, req.URL, bodyHash, mauthApp.epoch())
+	if err != nil {
+		return err
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	for header, value := range madeHeaders {
+		req.Header.Set(header, value)
+	}
+	return nil
+}
+
+// makeBodyHashRequest formulates a request signed with the body hash, which sends body as it is
+// read; size is the length of the body, or -1 if it is not known
+func (mauthApp *MAuthApp) makeBodyHashRequest(method string, rawurl string, body io.Reader, size int64,
+	bodyHash string, extraHeaders map[string][]string) (req *http.Request, err error) {
+	req, err = http.NewRequest(method, rawurl, body)
+	if err != nil {
+		return nil, err
+	}
+	if size >= 0 {
+		req.ContentLength = size
+	}
+	madeHeaders, err := mauthApp.makeBodyHashHeaders(method, req.URL, bodyHash, mauthApp.epoch())
+	if err != nil {
+		return nil, err
+	}
+	decorateRequest(req, madeHeaders, "", extraHeaders)
+	return req, nil
+}
+
+// MAuthClient.PostWithBodyHash executes a POST request against a targetURL, signed with the hex encoded
+// SHA-512 of the body so that it is sent as it is read.  size is the length of the body, or -1 if it
+// is not known.  The request is signed with V2 only, so version fallback does not apply.
+func (mauthClient *MAuthClient) PostWithBodyHash(targetURL string, data io.Reader, size int64,
+	bodyHash string) (response *http.Response, err error) {
+	return mauthClient.doWithBodyHash("POST", targetURL, data, size, bodyHash)
+}
+
+// MAuthClient.PutWithBodyHash executes a PUT request against a targetURL, signed with the hex encoded
+// SHA-512 of the body as PostWithBodyHash is
+func (mauthClient *MAuthClient) PutWithBodyHash(targetURL string, data io.Reader, size int64,
+	bodyHash string) (response *http.Response, err error) {
+	return mauthClient.doWithBodyHash("PUT", targetURL, data, size, bodyHash)
+}
+
+// doWithBodyHash signs a request with the body hash and sends it
+func (mauthClient *MAuthClient) doWithBodyHash(method string, targetURL string, data io.Reader, size int64,
+	bodyHash string) (response *http.Response, err error) {
+	fullUrl, err := mauthClient.fullURL(targetURL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := mauthClient.mauthApp.makeBodyHashRequest(method, fullUrl, data, size, bodyHash,
+		mauthClient.extraHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	client := http.Client{}
+	response, err = client.Do(req)
+	if err == nil {
+		mauthClient.mauthApp.observeSkew(response)
+	}
+	return
+}
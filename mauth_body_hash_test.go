@@ -0,0 +1,82 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test a request signed with the body hash verifies against the body
+func TestSignRequestWithBodyHash(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	body := []byte(`{"export": "large"}`)
+	hashed := sha512.Sum512(body)
+	req, _ := http.NewRequest("PUT", "https://innovate.mdsol.com/api/v2/exports/1?part=2", bytes.NewReader(body))
+	if err := mauthApp.SignRequestWithBodyHash(req, strings.ToUpper(hex.EncodeToString(hashed[:]))); err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+	if req.Header.Get("X-MWS-Authentication") != "" {
+		t.Error("Unexpected V1 header when signing with a body hash")
+	}
+	if _, err := testVerifier(mauthApp).VerifyRequest(req); err != nil {
+		t.Error("Verification failed: ", err)
+	}
+	if read, _ := ioutil.ReadAll(req.Body); !bytes.Equal(read, body) {
+		t.Error("Expected the body to be left unread")
+	}
+}
+
+// Test a body hash which doesn't match the body fails verification
+func TestSignRequestWithBodyHashMismatch(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	hashed := sha512.Sum512([]byte("another body"))
+	req, _ := http.NewRequest("PUT", "https://innovate.mdsol.com/api/v2/exports/1", strings.NewReader("body"))
+	_ = mauthApp.SignRequestWithBodyHash(req, hex.EncodeToString(hashed[:]))
+	if _, err := testVerifier(mauthApp).VerifyRequest(req); err != ErrInvalidSignature {
+		t.Error("Expected ErrInvalidSignature, got ", err)
+	}
+}
+
+// Test invalid hashes and V1 only Apps are rejected
+func TestSignRequestWithBodyHashRejected(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	hashed := sha512.Sum512([]byte("body"))
+	for _, bodyHash := range []string{"", "not hex", hex.EncodeToString(hashed[:32])} {
+		req, _ := http.NewRequest("PUT", "https://innovate.mdsol.com/api/v2/exports/1", nil)
+		if err := mauthApp.SignRequestWithBodyHash(req, bodyHash); err != ErrInvalidBodyHash {
+			t.Errorf("%q: expected ErrInvalidBodyHash, got %v", bodyHash, err)
+		}
+	}
+	v1Only := mauthApp.withSignVersions([]SignVersion{SignV1})
+	req, _ := http.NewRequest("PUT", "https://innovate.mdsol.com/api/v2/exports/1", nil)
+	if err := v1Only.SignRequestWithBodyHash(req, hex.EncodeToString(hashed[:])); err != ErrV1RequiresBody {
+		t.Error("Expected ErrV1RequiresBody, got ", err)
+	}
+}
+
+// Test the client sends a body signed with its hash
+func TestMAuthClient_PutWithBodyHash(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	var received []byte
+	server := streamServer(t, mauthApp, &received)
+	defer server.Close()
+	client, _ := mauthApp.CreateClient(server.URL)
+	body := []byte("precomputed upload")
+	hashed := sha512.Sum512(body)
+	response, err := client.PutWithBodyHash("/api/v2/uploads", bytes.NewReader(body), int64(len(body)),
+		hex.EncodeToString(hashed[:]))
+	if err != nil {
+		t.Fatal("PutWithBodyHash Failed: ", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Error("Expected 200, got ", response.StatusCode)
+	}
+	if !bytes.Equal(received, body) {
+		t.Errorf("Expected %q, got %q", body, received)
+	}
+}
@@ -14,9 +14,13 @@
 package go_mauth_client
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 )
 
 // MAuthClient struct holds all the context for a MAuth Client
@@ -24,6 +28,14 @@ type MAuthClient struct {
 	mauthApp     *MAuthApp
 	baseUrl      *url.URL
 	extraHeaders map[string][]string
+	// versionFallback enables protocol version negotiation, see EnableVersionFallback
+	versionFallback bool
+	// hostVersions remembers the versions negotiated with each host
+	hostVersions map[string][]SignVersion
+	// gzip compresses request bodies at gzipLevel, see EnableGzip
+	gzip      bool
+	gzipLevel int
+	mutex     sync.Mutex
 }
 
 // CreateClient creates a MAuth Client for the baseUrl
@@ -49,6 +61,23 @@ func (client *MAuthClient) SetHeader(headerName, headerValue string) {
 	client.extraHeaders[headerName] = header
 }
 
+// EnableVersionFallback turns on protocol version negotiation: a request to a host is first signed
+// with the App's versions other than V1, and if the host rejects it as an unsupported version it is
+// retried once, signed with V1 as well.  The versions a host accepted, shown by a response without an
+// error status, are remembered for later requests to it.
+//
+// Only a 401 response whose WWW-Authenticate header challenges for MWS, and for none of the versions
+// signed with, is taken as an unsupported version; any other rejection is an authentication failure
+// and is returned as it is.
+func (client *MAuthClient) EnableVersionFallback() {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.versionFallback = true
+	if client.hostVersions == nil {
+		client.hostVersions = make(map[string][]SignVersion)
+	}
+}
+
 // fullURL returns the full URL, if we have a path it will prepend the base_url
 func (mauthClient *MAuthClient) fullURL(targetUrl string) (fullUrl string, err error) {
 	var parsedUrl *url.URL
@@ -69,64 +98,213 @@ func (mauthClient *MAuthClient) fullURL(targetUrl string) (fullUrl string, err e
 
 // MAuthClient.Get executes a GET request against targetURL
 func (mauthClient *MAuthClient) Get(targetURL string) (response *http.Response, err error) {
+	return mauthClient.do("GET", targetURL, strings.NewReader(""), "")
+}
+
+// MAuthClient.Delete executes a DELETE request against targetURL
+func (mauthClient *MAuthClient) Delete(targetURL string) (response *http.Response, err error) {
+	return mauthClient.do("DELETE", targetURL, strings.NewReader(""), "")
+}
+
+// MAuthClient.Post executes a POST request against a targetURL
+func (mauthClient *MAuthClient) Post(targetURL string, data string) (response *http.Response, err error) {
+	return mauthClient.do("POST", targetURL, strings.NewReader(data), jsonContentType([]byte(data)))
+}
+
+// MAuthClient.Put executes a PUT request against a targetURL
+func (mauthClient *MAuthClient) Put(targetURL string, data string) (response *http.Response, err error) {
+	return mauthClient.do("PUT", targetURL, strings.NewReader(data), jsonContentType([]byte(data)))
+}
+
+// MAuthClient.PostBytes executes a POST request against a targetURL with a binary body
+func (mauthClient *MAuthClient) PostBytes(targetURL string, data []byte) (response *http.Response, err error) {
+	return mauthClient.do("POST", targetURL, bytes.NewReader(data), jsonContentType(data))
+}
+
+// MAuthClient.PutBytes executes a PUT request against a targetURL with a binary body
+func (mauthClient *MAuthClient) PutBytes(targetURL string, data []byte) (response *http.Response, err error) {
+	return mauthClient.do("PUT", targetURL, bytes.NewReader(data), jsonContentType(data))
+}
+
+// MAuthClient.PostReader executes a POST request against a targetURL, streaming the body from data.
+// An io.ReadSeeker, such as an os.File, is read once to hash it and again to send it; any other
+// reader is first copied to a temporary file.  No Content-Type is set, add one with SetHeader.
+func (mauthClient *MAuthClient) PostReader(targetURL string, data io.Reader) (response *http.Response, err error) {
+	return mauthClient.doReader("POST", targetURL, data)
+}
+
+// MAuthClient.PutReader executes a PUT request against a targetURL, streaming the body from data
+// as PostReader does
+func (mauthClient *MAuthClient) PutReader(targetURL string, data io.Reader) (response *http.Response, err error) {
+	return mauthClient.doReader("PUT", targetURL, data)
+}
+
+// doReader sends a request with a streamed body, spooling it first if it can't be rewound.
+// An *os.File is an io.ReadSeeker even when it is a pipe or stdin, so seeking is tried.
+func (mauthClient *MAuthClient) doReader(method string, targetURL string, data io.Reader) (response *http.Response, err error) {
+	body, seekable := data.(io.ReadSeeker)
+	if seekable {
+		_, err := body.Seek(0, io.SeekCurrent)
+		seekable = err == nil
+	}
+	if !seekable {
+		file, cleanup, err := spoolBody(data)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		body = file
+	}
+	return mauthClient.do(method, targetURL, body, "")
+}
+
+// do signs and sends a request, negotiating the protocol version with the host if fallback is enabled
+func (mauthClient *MAuthClient) do(method string, targetURL string, body io.ReadSeeker,
+	contentType string) (response *http.Response, err error) {
 	fullUrl, err := mauthClient.fullURL(targetURL)
 	if err != nil {
 		return nil, err
 	}
-	req, err := mauthClient.mauthApp.makeRequest("GET", fullUrl, "", mauthClient.extraHeaders)
+	body, compressed, err := mauthClient.compressBody(body)
 	if err != nil {
 		return nil, err
 	}
+	extraHeaders := mauthClient.extraHeaders
+	if compressed {
+		// the Content-Encoding is added to the extra headers for this request only
+		extraHeaders = map[string][]string{"Content-Encoding": {"gzip"}}
+		for header, values := range mauthClient.extraHeaders {
+			extraHeaders[header] = values
+		}
+	}
+	mauthClient.mutex.Lock()
+	versionFallback := mauthClient.versionFallback
+	mauthClient.mutex.Unlock()
+	if !versionFallback {
+		return mauthClient.send(mauthClient.mauthApp, method, fullUrl, body, contentType, extraHeaders)
+	}
 
-	client := http.Client{}
-	response, err = client.Do(req)
-	return
-}
-
-// MAuthClient.Delete executes a DELETE request against targetURL
-func (mauthClient *MAuthClient) Delete(targetURL string) (response *http.Response, err error) {
-	fullUrl, err := mauthClient.fullURL(targetURL)
+	parsedUrl, err := url.Parse(fullUrl)
 	if err != nil {
 		return nil, err
 	}
-	req, err := mauthClient.mauthApp.makeRequest("DELETE", fullUrl, "", mauthClient.extraHeaders)
-	if err != nil {
-		return nil, err
+	host := parsedUrl.Host
+	if versions, known := mauthClient.negotiatedVersions(host); known {
+		return mauthClient.send(mauthClient.mauthApp.withSignVersions(versions), method, fullUrl, body, contentType,
+			extraHeaders)
 	}
 
-	client := http.Client{}
-	response, err = client.Do(req)
-	return
-}
+	// the App's versions other than V1 are kept in both attempts
+	withoutV1 := []SignVersion{}
+	for _, version := range mauthClient.mauthApp.SignVersions {
+		if version != SignV1 {
+			withoutV1 = append(withoutV1, version)
+		}
+	}
+	if len(mauthClient.mauthApp.SignVersions) == 0 {
+		withoutV1 = []SignVersion{SignV2}
+	}
+	// an App which doesn't sign with V1 has nothing to fall back to
+	if len(withoutV1) == 0 || !mauthClient.mauthApp.signsWith(SignV1) {
+		return mauthClient.send(mauthClient.mauthApp, method, fullUrl, body, contentType, extraHeaders)
+	}
 
-// MAuthClient.Post executes a POST request against a targetURL
-func (mauthClient *MAuthClient) Post(targetURL string, data string) (response *http.Response, err error) {
-	fullUrl, err := mauthClient.fullURL(targetURL)
+	// the body is sent twice if the host rejects the first attempt
+	start, err := body.Seek(0, io.SeekCurrent)
 	if err != nil {
 		return nil, err
 	}
-	req, err := mauthClient.mauthApp.makeRequest("POST", fullUrl, data, mauthClient.extraHeaders)
+	response, err = mauthClient.send(mauthClient.mauthApp.withSignVersions(withoutV1), method, fullUrl,
+		body, contentType, extraHeaders)
 	if err != nil {
 		return nil, err
 	}
+	if !isUnsupportedVersion(response, withoutV1) {
+		// only a success shows the host accepted the versions; a bare 401 or a 5xx says nothing about them
+		if isSuccess(response) {
+			mauthClient.rememberVersions(host, withoutV1)
+		}
+		return response, nil
+	}
+	// discard the rejection so the connection can be reused for the retry
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+	_ = response.Body.Close()
+	if _, err := body.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
 
-	client := http.Client{}
-	response, err = client.Do(req)
-	return
-}
-
-// MAuthClient.Put executes a PUT request against a targetURL
-func (mauthClient *MAuthClient) Put(targetURL string, data string) (response *http.Response, err error) {
-	fullUrl, err := mauthClient.fullURL(targetURL)
+	versions := append([]SignVersion{SignV1}, withoutV1...)
+	response, err = mauthClient.send(mauthClient.mauthApp.withSignVersions(versions), method, fullUrl, body,
+		contentType, extraHeaders)
 	if err != nil {
 		return nil, err
 	}
-	req, err := mauthClient.mauthApp.makeRequest("PUT", fullUrl, data, mauthClient.extraHeaders)
+	if isSuccess(response) {
+		mauthClient.rememberVersions(host, versions)
+	}
+	return response, nil
+}
+
+// isSuccess reports whether the host answered without an error status
+func isSuccess(response *http.Response) bool {
+	return response.StatusCode < http.StatusBadRequest
+}
+
+// send builds the request signed by mauthApp and sends it
+func (mauthClient *MAuthClient) send(mauthApp *MAuthApp, method string, fullUrl string, body io.ReadSeeker,
+	contentType string, extraHeaders map[string][]string) (response *http.Response, err error) {
+	req, err := mauthApp.makeStreamingRequest(method, fullUrl, body, contentType, extraHeaders)
 	if err != nil {
 		return nil, err
 	}
 
 	client := http.Client{}
 	response, err = client.Do(req)
+	if err == nil {
+		mauthApp.observeSkew(response)
+	}
 	return
 }
+
+// negotiatedVersions returns the versions remembered for host
+func (mauthClient *MAuthClient) negotiatedVersions(host string) ([]SignVersion, bool) {
+	mauthClient.mutex.Lock()
+	defer mauthClient.mutex.Unlock()
+	versions, known := mauthClient.hostVersions[host]
+	return versions, known
+}
+
+// rememberVersions records the versions host accepted
+func (mauthClient *MAuthClient) rememberVersions(host string, versions []SignVersion) {
+	mauthClient.mutex.Lock()
+	defer mauthClient.mutex.Unlock()
+	mauthClient.hostVersions[host] = versions
+}
+
+// isUnsupportedVersion reports whether a response to a request signed without V1 rejects the protocol
+// versions: a 401 whose WWW-Authenticate challenges are for MWS and for none of the versions signed with.
+// RFC 7235 section 3.1 has a 401 carry a challenge for each scheme the host accepts, so a host which
+// challenges for MWS alone is asking for V1; a 401 without the challenge can't be told apart from a bad
+// signature, so it isn't retried.
+func isUnsupportedVersion(response *http.Response, versions []SignVersion) bool {
+	if response.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	challenges := make(map[string]bool)
+	for _, value := range response.Header.Values("WWW-Authenticate") {
+		for _, challenge := range strings.Split(value, ",") {
+			if fields := strings.Fields(challenge); len(fields) > 0 {
+				challenges[strings.ToUpper(fields[0])] = true
+			}
+		}
+	}
+	if !challenges[schemeV1.Token()] {
+		return false
+	}
+	for _, version := range versions {
+		if scheme, known := LookupSignatureScheme(version); known && challenges[strings.ToUpper(scheme.Token())] {
+			return false
+		}
+	}
+	return true
+}
@@ -13,7 +13,7 @@ import (
 )
 
 func TestFullURLWithRelative(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient("https://innovate.mdsol.com")
 	expected := "https://innovate.mdsol.com/api/v2/users.json"
 	actual, _ := client.fullURL("/api/v2/users.json")
@@ -32,7 +32,7 @@ func TestFullURLWithRelative(t *testing.T) {
 }
 
 func TestFullURLWithRelativeAndParams(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient("https://innovate.mdsol.com")
 	expected := "https://innovate.mdsol.com/api/v2/users.json"
 	actual, _ := client.fullURL("/api/v2/users.json")
@@ -51,7 +51,7 @@ func TestFullURLWithRelativeAndParams(t *testing.T) {
 }
 
 func TestFullURLWithActualURL(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient("https://innovate.mdsol.com")
 	expected := "https://balance-innovate.mdsol.com/api/v2/users.json"
 	actual, _ := client.fullURL("https://balance-innovate.mdsol.com/api/v2/users.json")
@@ -61,7 +61,7 @@ func TestFullURLWithActualURL(t *testing.T) {
 }
 
 func TestCreateClient(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient("https://innovate.mdsol.com")
 	if client.baseUrl.String() != "https://innovate.mdsol.com" {
 		t.Error("Base URL has changed")
@@ -72,7 +72,7 @@ func TestCreateClient(t *testing.T) {
 }
 
 func TestCreateClientBadURL(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	_, err := mauthApp.CreateClient("some_nonsense")
 	if err == nil {
 		t.Error("Bad URL should fail")
@@ -80,7 +80,7 @@ func TestCreateClientBadURL(t *testing.T) {
 }
 
 func TestMauthClient_fullURLBadURL(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient("https://innovate.mdsol.com")
 	_, err := client.fullURL("http://\x7finnovate.mdsol.com")
 	if err == nil {
@@ -88,7 +88,7 @@ func TestMauthClient_fullURLBadURL(t *testing.T) {
 	}
 }
 func TestMauthClient_fullURLPathBadURL(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	_, err := mauthApp.CreateClient("https://\x7finnovate.mdsol.com")
 	if err == nil {
 		t.Error("Expected error with Bad URL")
@@ -96,7 +96,7 @@ func TestMauthClient_fullURLPathBadURL(t *testing.T) {
 }
 
 func TestMauthClient_fullURLPath(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient("https://innovate.mdsol.com")
 	fullURL, err := client.fullURL("/subject/city")
 	if err != nil {
@@ -141,7 +141,7 @@ func TestMAuthClient_Get(t *testing.T) {
 
 	}))
 	defer server.Close()
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient(server.URL)
 	// Make the Get call
 	_, err := client.Get("/api/v2/users.json")
@@ -175,7 +175,7 @@ func TestMAuthClient_Get_DisabledV1(t *testing.T) {
 
 	}))
 	defer server.Close()
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), true})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: true})
 	client, _ := mauthApp.CreateClient(server.URL)
 	// Make the Get call
 	_, err := client.Get("/api/v2/users.json")
@@ -195,7 +195,7 @@ func TestMAuthClient_Get_DisabledV1(t *testing.T) {
 
 // Get with bad URL
 func TestMAuthClient_GetBadURL(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient("https://innovate.mdsol.com")
 	// Make the Get call
 	_, err := client.Get("https://innovate.mdsol.com/api/v2/\x7fusers.json")
@@ -206,7 +206,7 @@ func TestMAuthClient_GetBadURL(t *testing.T) {
 
 // Post with bad URL
 func TestMAuthClient_PostBadURL(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient("https://innovate.mdsol.com")
 	// Make the Post call
 	_, err := client.Post("https://innovate.mdsol.com/api/v2/\x7fusers.json", "")
@@ -217,7 +217,7 @@ func TestMAuthClient_PostBadURL(t *testing.T) {
 
 // Put with bad URL
 func TestMAuthClient_PutBadURL(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient("https://innovate.mdsol.com")
 	// Make the Post call
 	_, err := client.Put("https://innovate.mdsol.com/api/v2/\x7fusers.json", "")
@@ -228,7 +228,7 @@ func TestMAuthClient_PutBadURL(t *testing.T) {
 
 // Put with bad URL
 func TestMAuthClient_DeleteBadURL(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient("https://innovate.mdsol.com")
 	// Make the Post call
 	_, err := client.Delete("https://innovate.mdsol.com/api/v2/\x7fusers.json")
@@ -253,7 +253,7 @@ func TestMAuthClient_Delete(t *testing.T) {
 		_, _ = fmt.Fprintln(w, `{"fake twitter json string"}`)
 	}))
 	defer server.Close()
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient(server.URL)
 	// Make the Get call
 	_, err := client.Delete("/api/v2/users.json")
@@ -293,7 +293,7 @@ func TestMAuthClient_Post(t *testing.T) {
 		_, _ = fmt.Fprint(w, "{\"fake twitter json string\"}")
 	}))
 	defer server.Close()
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient(server.URL)
 	// Make the Get call
 	response, err := client.Post("/api/v2/users.json", `{"uuid":"1234-1234"}`)
@@ -342,7 +342,7 @@ func TestMAuthClient_Put(t *testing.T) {
 		_, _ = fmt.Fprint(w, "{\"fake twitter json string\"}")
 	}))
 	defer server.Close()
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient(server.URL)
 	// Make the Get call
 	response, err := client.Put("/api/v2/users.json", `{"uuid":"1234-1234"}`)
@@ -396,7 +396,7 @@ func TestMAuthClient_SetHeader(t *testing.T) {
 		_, _ = fmt.Fprint(w, "{\"fake twitter json string\"}")
 	}))
 	defer server.Close()
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	client, _ := mauthApp.CreateClient(server.URL)
 	// Set a Header
 	client.SetHeader("Mcc-Version", "v2019-03-22")
@@ -437,7 +437,7 @@ func ExampleMAuthApp_CreateClient() {
 	var keyPath = filepath.Join("test", "private_key.pem")
 	// create a MAuth mAuthApp
 	var mAuthApp *MAuthApp
-	mAuthApp, err := LoadMauth(MAuthOptions{appUUID, keyPath, false})
+	mAuthApp, err := LoadMauth(MAuthOptions{AppId: appUUID, PrivateKey: keyPath, DisableV1: false})
 	if err != nil {
 		log.Fatal("Unable to create mAuthApp: ", err)
 	}
@@ -462,7 +462,7 @@ func ExampleMAuthClient_Get() {
 	var keyPath = filepath.Join("test", "private_key.pem")
 	// create a MAuth mAuthApp
 	var mAuthApp *MAuthApp
-	mAuthApp, err := LoadMauth(MAuthOptions{appUUID, keyPath, false})
+	mAuthApp, err := LoadMauth(MAuthOptions{AppId: appUUID, PrivateKey: keyPath, DisableV1: false})
 	if err != nil {
 		log.Fatal("Unable to create mAuthApp: ", err)
 	}
@@ -491,7 +491,7 @@ func ExampleMAuthClient_Post() {
 	var keyPath = filepath.Join("test", "private_key.pem")
 	// create a MAuth mAuthApp
 	var mAuthApp *MAuthApp
-	mAuthApp, err := LoadMauth(MAuthOptions{appUUID, keyPath, false})
+	mAuthApp, err := LoadMauth(MAuthOptions{AppId: appUUID, PrivateKey: keyPath, DisableV1: false})
 	if err != nil {
 		log.Fatal("Unable to create mAuthApp: ", err)
 	}
@@ -548,3 +548,153 @@ func ExampleMAuthClient_Post() {
 		string(data))
 	println("Got a status code of", response.StatusCode, "for request to create Study", studyUUID)
 }
+
+// Test the version fallback retries a V2 only request rejected by a V1 only host, and remembers the host
+func TestMAuthClient_VersionFallback(t *testing.T) {
+	var requests, v2Only int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if !hasMWSHeader(r) {
+			v2Only++
+			w.Header().Set("WWW-Authenticate", "MWS")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{}`)
+	}))
+	defer server.Close()
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	client, _ := mauthApp.CreateClient(server.URL)
+	client.EnableVersionFallback()
+
+	response, err := client.Post("/api/v2/users.json", `{"user": "test"}`)
+	if err != nil {
+		t.Fatal("Post Failed: ", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Error("Expected the retry to succeed, got ", response.StatusCode)
+	}
+	if requests != 2 || v2Only != 1 {
+		t.Errorf("Expected a V2 only request and a retry, got %d requests with %d V2 only", requests, v2Only)
+	}
+	// the host is remembered as needing V1
+	_, _ = client.Get("/api/v2/users.json")
+	if requests != 3 || v2Only != 1 {
+		t.Errorf("Expected the negotiated versions to be reused, got %d requests with %d V2 only", requests, v2Only)
+	}
+}
+
+// Test the version fallback sends V2 only to a host which accepts it
+func TestMAuthClient_VersionFallbackV2Accepted(t *testing.T) {
+	var requests, withV1 int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if hasMWSHeader(r) {
+			withV1++
+		}
+		_, _ = fmt.Fprintln(w, `{}`)
+	}))
+	defer server.Close()
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	client, _ := mauthApp.CreateClient(server.URL)
+	client.EnableVersionFallback()
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get("/api/v2/users.json"); err != nil {
+			t.Fatal("Get Failed: ", err)
+		}
+	}
+	if requests != 2 || withV1 != 0 {
+		t.Errorf("Expected 2 V2 only requests, got %d requests with %d signed with V1", requests, withV1)
+	}
+}
+
+// Test a 401 which doesn't challenge for MWS alone is an authentication failure and not retried
+func TestMAuthClient_VersionFallbackAuthenticationFailure(t *testing.T) {
+	for _, challenge := range []string{"", "MWSV2", "MWS, MWSV2", `Bearer realm="mauth"`} {
+		var requests int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if challenge != "" {
+				w.Header().Set("WWW-Authenticate", challenge)
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+		client, _ := mauthApp.CreateClient(server.URL)
+		client.EnableVersionFallback()
+		response, err := client.Get("/api/v2/users.json")
+		server.Close()
+		if err != nil {
+			t.Fatal("Get Failed: ", err)
+		}
+		if response.StatusCode != http.StatusUnauthorized || requests != 1 {
+			t.Errorf("%q: expected a single rejected request, got %d requests ending in %d", challenge, requests,
+				response.StatusCode)
+		}
+	}
+}
+
+// Test a bare 401 or a 503 doesn't pin the host to the versions signed with, so a later challenge for MWS
+// is still retried with V1
+func TestMAuthClient_VersionFallbackErrorNotRemembered(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusServiceUnavailable} {
+		var requests int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests == 1 {
+				w.WriteHeader(status)
+				return
+			}
+			if !hasMWSHeader(r) {
+				w.Header().Set("WWW-Authenticate", "MWS")
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+		mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+		client, _ := mauthApp.CreateClient(server.URL)
+		client.EnableVersionFallback()
+		first, err := client.Get("/api/v2/users.json")
+		if err != nil {
+			t.Fatal("Get Failed: ", err)
+		}
+		second, err := client.Get("/api/v2/users.json")
+		server.Close()
+		if err != nil {
+			t.Fatal("Get Failed: ", err)
+		}
+		if first.StatusCode != status || second.StatusCode != http.StatusOK || requests != 3 {
+			t.Errorf("%d: expected the error, then a retry with V1, got %d and %d after %d requests", status,
+				first.StatusCode, second.StatusCode, requests)
+		}
+	}
+}
+
+// Test the App's registered schemes are signed with in both attempts
+func TestMAuthClient_VersionFallbackKeepsSchemes(t *testing.T) {
+	registerSchemes(t)
+	var signed [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signed = append(signed, []string{r.Header.Get("X-MWS-Authentication"), r.Header.Get("MCC-Authentication"),
+			r.Header.Get("MCC-PSS-Authentication")})
+		if !hasMWSHeader(r) {
+			w.Header().Set("WWW-Authenticate", "MWS")
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"),
+		SignVersions: []SignVersion{SignV1, SignV2, "v3-pss"}})
+	client, _ := mauthApp.CreateClient(server.URL)
+	client.EnableVersionFallback()
+	if _, err := client.Get("/api/v2/users.json"); err != nil {
+		t.Fatal("Get Failed: ", err)
+	}
+	if len(signed) != 2 {
+		t.Fatalf("Expected a request and a retry, got %d requests", len(signed))
+	}
+	for attempt, headers := range signed {
+		if headers[1] == "" || headers[2] == "" || (headers[0] != "") != (attempt == 1) {
+			t.Errorf("Attempt %d: unexpected headers %q", attempt, headers)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package go_mauth_client
+
+import (
+	"crypto/rand"
+	"io"
+	"time"
+)
+
+/*
+The time and randomness used when signing, which can be fixed so that signing the same request
+gives byte-identical headers, e.g. for golden file tests
+*/
+
+// FixedClock returns a clock for MAuthApp.Clock which always gives t
+func FixedClock(t time.Time) func() time.Time {
+	return func() time.Time {
+		return t
+	}
+}
+
+// epoch returns the signing time as seconds since the epoch, according to the App's Clock and
+// corrected by any measured skew
+func (mauthApp *MAuthApp) epoch() int64 {
+	now := mauthApp.localTime()
+	if mauthApp.SkewCorrection != nil {
+		now = now.Add(mauthApp.SkewCorrection.Offset())
+	}
+	return now.Unix()
+}
+
+// localTime returns the time according to the App's Clock, without any skew correction
+func (mauthApp *MAuthApp) localTime() time.Time {
+	if mauthApp.Clock != nil {
+		return mauthApp.Clock()
+	}
+	return time.Now()
+}
+
+// random returns the App's source of randomness, crypto/rand.Reader unless Rand is set
+func (mauthApp *MAuthApp) random() io.Reader {
+	if mauthApp.Rand != nil {
+		return mauthApp.Rand
+	}
+	return rand.Reader
+}
@@ -0,0 +1,72 @@
+package go_mauth_client
+
+import (
+	mathrand "math/rand"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deterministicApp loads the test App with a fixed clock and a seeded source of randomness
+func deterministicApp() *MAuthApp {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	mauthApp.Clock = FixedClock(time.Unix(1309891855, 0))
+	mauthApp.Rand = mathrand.New(mathrand.NewSource(1))
+	return mauthApp
+}
+
+// Test signing the same request twice gives byte-identical headers
+func TestDeterministicSignRequest(t *testing.T) {
+	headers := []http.Header{}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("POST", "https://innovate.mdsol.com/api/v2/users.json?b=2&a=1",
+			strings.NewReader(`{"user": "test"}`))
+		if err := deterministicApp().SignRequest(req); err != nil {
+			t.Fatal("Unexpected error ", err)
+		}
+		headers = append(headers, req.Header)
+	}
+	if !reflect.DeepEqual(headers[0], headers[1]) {
+		t.Errorf("Expected identical headers, got %v and %v", headers[0], headers[1])
+	}
+	if headers[0].Get("X-MWS-Time") != "1309891855" || headers[0].Get("MCC-Time") != "1309891855" {
+		t.Error("Expected the fixed time, got ", headers[0])
+	}
+}
+
+// Test the randomness in WebSocket handshakes and multipart bodies comes from the App
+func TestDeterministicRandom(t *testing.T) {
+	keys := []string{}
+	contentTypes := []string{}
+	for i := 0; i < 2; i++ {
+		req, err := deterministicApp().MakeWebSocketRequest("wss://dashboard.mdsol.com/studies/feed")
+		if err != nil {
+			t.Fatal("Unexpected error ", err)
+		}
+		keys = append(keys, req.Header.Get("Sec-WebSocket-Key")+req.Header.Get("MCC-Authentication"))
+		body, contentType, err := newMultipartBody(map[string]string{"a": "1"}, nil, deterministicApp().random())
+		if err != nil {
+			t.Fatal("Unexpected error ", err)
+		}
+		contentTypes = append(contentTypes, contentType)
+		_ = body.Close()
+	}
+	if keys[0] != keys[1] {
+		t.Error("Expected the same WebSocket headers, got ", keys)
+	}
+	if contentTypes[0] != contentTypes[1] {
+		t.Error("Expected the same multipart boundary, got ", contentTypes)
+	}
+}
+
+// Test a signed message is reproducible with a fixed clock
+func TestDeterministicSignMessage(t *testing.T) {
+	first, _ := deterministicApp().SignMessage("study.created", []byte(`{"study": 1}`))
+	second, _ := deterministicApp().SignMessage("study.created", []byte(`{"study": 1}`))
+	if !reflect.DeepEqual(first, second) {
+		t.Error("Expected identical envelopes")
+	}
+}
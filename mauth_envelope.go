@@ -0,0 +1,116 @@
+package go_mauth_client
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+/*
+Signing and verification of messages sent over queues and event buses, rather than HTTP
+*/
+
+// MessageSignatureMethod takes the place of the HTTP method in the string to sign for a message
+const MessageSignatureMethod = "MESSAGE"
+
+// MessageSignatureDomain takes the place of the query string in the string to sign for a message.
+// A canonical query string always encodes the ":", so a message signature is never valid for a request.
+const MessageSignatureDomain = "mauth:message"
+
+// DefaultMessageTimeWindow is the maximum age of a signed message when MessageTimeWindow is zero
+const DefaultMessageTimeWindow = time.Hour
+
+// SignedEnvelope carries a message payload along with the MAuth signature of the App that produced it.
+// The payload is base64 encoded when the envelope is marshalled to JSON.
+type SignedEnvelope struct {
+	Subject        string `json:"subject"`
+	Payload        []byte `json:"payload"`
+	Authentication string `json:"mcc_authentication"`
+	Time           string `json:"mcc_time"`
+}
+
+// MakeMessageSignatureString generates the string to be signed for a message, following the
+// MCC layout with the subject (topic, queue or event name) in place of the path and
+// MessageSignatureDomain in place of the query string
+func MakeMessageSignatureString(mauthApp *MAuthApp, subject string, payload []byte, epoch int64) string {
+	if epoch == -1 {
+		epoch = mauthApp.epoch()
+	}
+	hashedPayload := sha512.Sum512(payload)
+	stringToSign := StringToSign{Version: SignV2,
+		Method:   MessageSignatureMethod,
+		Path:     subject,
+		BodyHash: hex.EncodeToString(hashedPayload[:]),
+		AppId:    mauthApp.AppId,
+		Time:     epoch,
+		Query:    MessageSignatureDomain}
+	return stringToSign.String()
+}
+
+// SignMessage signs the payload published under subject and returns it in a SignedEnvelope
+func (mauthApp *MAuthApp) SignMessage(subject string, payload []byte) (*SignedEnvelope, error) {
+	secondsSinceEpoch := mauthApp.epoch()
+	stringToSign := MakeMessageSignatureString(mauthApp, subject, payload, secondsSinceEpoch)
+	signedString, err := SignStringV2(mauthApp, stringToSign)
+	if err != nil {
+		return nil, err
+	}
+	headers := MakeAuthenticationHeadersV2(mauthApp, signedString, secondsSinceEpoch)
+	return &SignedEnvelope{Subject: subject,
+		Payload:        payload,
+		Authentication: headers["MCC-Authentication"],
+		Time:           headers["MCC-Time"]}, nil
+}
+
+// Headers returns the signature as message headers (or attributes), for transports which carry
+// the payload and subject separately
+func (envelope *SignedEnvelope) Headers() map[string]string {
+	return map[string]string{
+		"MCC-Authentication": envelope.Authentication,
+		"MCC-Time":           envelope.Time,
+	}
+}
+
+// EnvelopeFromHeaders rebuilds a SignedEnvelope from a received message and its headers.
+// Header names are matched without regard to case.
+func EnvelopeFromHeaders(subject string, payload []byte, headers map[string]string) *SignedEnvelope {
+	envelope := &SignedEnvelope{Subject: subject, Payload: payload}
+	for name, value := range headers {
+		switch strings.ToLower(name) {
+		case "mcc-authentication":
+			envelope.Authentication = value
+		case "mcc-time":
+			envelope.Time = value
+		}
+	}
+	return envelope
+}
+
+// VerifyMessage authenticates a SignedEnvelope, returning the App UUID that signed it
+func (verifier *MAuthVerifier) VerifyMessage(envelope *SignedEnvelope) (appId string, err error) {
+	if envelope.Authentication == "" {
+		return "", ErrMissingAuthentication
+	}
+	appId, signature, err := ParseAuthenticationHeaderV2(envelope.Authentication)
+	if err != nil {
+		return "", err
+	}
+	window := verifier.MessageTimeWindow
+	if window == 0 {
+		window = DefaultMessageTimeWindow
+	}
+	epoch, err := checkTimeWindow(envelope.Time, window, verifier.now())
+	if err != nil {
+		return "", err
+	}
+	publicKey, err := verifier.KeyProvider.PublicKey(appId)
+	if err != nil {
+		return "", err
+	}
+	stringToSign := MakeMessageSignatureString(&MAuthApp{AppId: appId}, envelope.Subject, envelope.Payload, epoch)
+	if err := VerifyStringV2(publicKey, stringToSign, signature); err != nil {
+		return "", err
+	}
+	return appId, nil
+}
@@ -0,0 +1,115 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMakeMessageSignatureString(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	expected := "MESSAGE" + "\n" + "studies.created" + "\n" +
+		"cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e" + "\n" +
+		app_id + "\n" + "1500000000" + "\n" + "mauth:message"
+	actual := MakeMessageSignatureString(mauthApp, "studies.created", []byte{}, 1500000000)
+	if actual != expected {
+		t.Error("Signature String doesn't match: Expected ", strings.Replace(expected, "\n", " ", -1),
+			"Actual ", strings.Replace(actual, "\n", " ", -1))
+	}
+}
+
+func TestMAuthApp_SignMessage(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	envelope, err := mauthApp.SignMessage("studies.created", []byte(`{"uuid":"1234-1234"}`))
+	if err != nil {
+		t.Error("Error signing message: ", err)
+	}
+	if !strings.HasPrefix(envelope.Authentication, "MWSV2 "+app_id+":") {
+		t.Error("Unexpected authentication: ", envelope.Authentication)
+	}
+	appId, err := testVerifier(mauthApp).VerifyMessage(envelope)
+	if err != nil {
+		t.Error("Message did not verify: ", err)
+	}
+	if appId != app_id {
+		t.Error("Unexpected App UUID ", appId)
+	}
+}
+
+func TestSignedEnvelope_JSON(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	envelope, _ := mauthApp.SignMessage("studies.created", []byte{0x00, 0xff, 0x10})
+	content, _ := json.Marshal(envelope)
+	var received SignedEnvelope
+	if err := json.Unmarshal(content, &received); err != nil {
+		t.Error("Error parsing envelope: ", err)
+	}
+	if _, err := testVerifier(mauthApp).VerifyMessage(&received); err != nil {
+		t.Error("Message did not verify: ", err)
+	}
+}
+
+func TestEnvelopeFromHeaders(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	envelope, _ := mauthApp.SignMessage("studies.created", []byte("payload"))
+	headers := map[string]string{}
+	for name, value := range envelope.Headers() {
+		headers[strings.ToLower(name)] = value
+	}
+	received := EnvelopeFromHeaders("studies.created", []byte("payload"), headers)
+	if _, err := testVerifier(mauthApp).VerifyMessage(received); err != nil {
+		t.Error("Message did not verify: ", err)
+	}
+	received = EnvelopeFromHeaders("studies.deleted", []byte("payload"), headers)
+	if _, err := testVerifier(mauthApp).VerifyMessage(received); err != ErrInvalidSignature {
+		t.Error("Expected ErrInvalidSignature for a different subject, got ", err)
+	}
+	received = EnvelopeFromHeaders("studies.created", []byte("payload"), map[string]string{})
+	if _, err := testVerifier(mauthApp).VerifyMessage(received); err != ErrMissingAuthentication {
+		t.Error("Expected ErrMissingAuthentication for an unsigned message, got ", err)
+	}
+}
+
+func TestMAuthVerifier_VerifyMessageAge(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	epoch := time.Now().Add(-2 * time.Hour).Unix()
+	stringToSign := MakeMessageSignatureString(mauthApp, "studies.created", []byte("payload"), epoch)
+	signed, _ := SignStringV2(mauthApp, stringToSign)
+	envelope := EnvelopeFromHeaders("studies.created", []byte("payload"),
+		MakeAuthenticationHeadersV2(mauthApp, signed, epoch))
+	verifier := testVerifier(mauthApp)
+	if _, err := verifier.VerifyMessage(envelope); err != ErrTimeOutsideWindow {
+		t.Error("Expected ErrTimeOutsideWindow outside the DefaultMessageTimeWindow, got ", err)
+	}
+	verifier.MessageTimeWindow = 3 * time.Hour
+	if _, err := verifier.VerifyMessage(envelope); err != nil {
+		t.Error("Expected the message to verify within the MessageTimeWindow: ", err)
+	}
+	verifier.MessageTimeWindow = -1
+	if _, err := verifier.VerifyMessage(envelope); err != nil {
+		t.Error("Expected a negative MessageTimeWindow to accept any age: ", err)
+	}
+	envelope.Time = strconv.FormatInt(time.Now().Unix(), 10)
+	if _, err := verifier.VerifyMessage(envelope); err != ErrInvalidSignature {
+		t.Error("Expected ErrInvalidSignature for a changed time, got ", err)
+	}
+}
+
+// Test a message signature isn't valid for a HTTP request, even with a subject which looks like a path
+func TestMAuthVerifier_VerifyMessageAsRequest(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	envelope, _ := mauthApp.SignMessage("/api/v2/users.json", []byte("payload"))
+	req, _ := http.NewRequest(MessageSignatureMethod, "https://innovate.mdsol.com/api/v2/users.json",
+		bytes.NewReader([]byte("payload")))
+	for header, value := range envelope.Headers() {
+		req.Header.Set(header, value)
+	}
+	if _, err := testVerifier(mauthApp).VerifyRequest(req); err != ErrInvalidSignature {
+		t.Error("Expected ErrInvalidSignature for a message signature on a request, got ", err)
+	}
+}
@@ -0,0 +1,152 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+/*
+Gzip compressed request bodies.  The compressed bytes are what go on the wire, so they are what
+is signed, and a verifier checks the signature before decompressing.
+*/
+
+// gzipChunkSize is how much of the source is compressed at a time
+const gzipChunkSize = 32 * 1024
+
+// gzipBody compresses a body as it is read.  Compression with the same level is repeatable,
+// so rewinding starts the compression again rather than keeping the compressed bytes.
+type gzipBody struct {
+	source io.ReadSeeker
+	start  int64
+	level  int
+	buffer bytes.Buffer
+	writer *gzip.Writer
+	chunk  []byte
+	offset int64
+	done   bool
+}
+
+// newGzipBody compresses source from its current position
+func newGzipBody(source io.ReadSeeker, level int) (*gzipBody, error) {
+	start, err := source.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	body := &gzipBody{source: source, start: start, level: level, chunk: make([]byte, gzipChunkSize)}
+	body.writer, err = gzip.NewWriterLevel(&body.buffer, level)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Read returns compressed bytes, compressing more of the source when those buffered run out
+func (body *gzipBody) Read(p []byte) (int, error) {
+	for body.buffer.Len() == 0 && !body.done {
+		n, err := body.source.Read(body.chunk)
+		if n > 0 {
+			if _, err := body.writer.Write(body.chunk[:n]); err != nil {
+				return 0, err
+			}
+		}
+		if err == io.EOF {
+			if err := body.writer.Close(); err != nil {
+				return 0, err
+			}
+			body.done = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	if body.buffer.Len() == 0 {
+		return 0, io.EOF
+	}
+	n, _ := body.buffer.Read(p)
+	body.offset += int64(n)
+	return n, nil
+}
+
+// Seek reports the position, or rewinds to the start; the compressed body can't be seeked otherwise
+func (body *gzipBody) Seek(offset int64, whence int) (int64, error) {
+	switch {
+	case offset == 0 && whence == io.SeekCurrent:
+		return body.offset, nil
+	case offset == 0 && whence == io.SeekStart:
+		if _, err := body.source.Seek(body.start, io.SeekStart); err != nil {
+			return 0, err
+		}
+		body.buffer.Reset()
+		body.writer.Reset(&body.buffer)
+		body.offset = 0
+		body.done = false
+		return 0, nil
+	}
+	return 0, errors.New("A gzip body can only be rewound to the start")
+}
+
+// EnableGzip compresses the bodies the client sends with gzip at the given level (e.g.
+// gzip.DefaultCompression), setting Content-Encoding: gzip.  The compressed bytes are signed.
+// Empty bodies and requests signed with a body hash are sent uncompressed.
+func (client *MAuthClient) EnableGzip(level int) error {
+	if _, err := gzip.NewWriterLevel(ioutil.Discard, level); err != nil {
+		return err
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.gzip = true
+	client.gzipLevel = level
+	return nil
+}
+
+// compressBody wraps a body to be compressed if the client has gzip enabled and the body isn't empty
+func (mauthClient *MAuthClient) compressBody(body io.ReadSeeker) (io.ReadSeeker, bool, error) {
+	mauthClient.mutex.Lock()
+	compress, level := mauthClient.gzip, mauthClient.gzipLevel
+	mauthClient.mutex.Unlock()
+	if !compress {
+		return body, false, nil
+	}
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, false, err
+	}
+	end, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := body.Seek(start, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+	if end == start {
+		return body, false, nil
+	}
+	compressed, err := newGzipBody(body, level)
+	if err != nil {
+		return nil, false, err
+	}
+	return compressed, true, nil
+}
+
+// isGzipEncoded reports whether the request body is gzip compressed
+func isGzipEncoded(header http.Header) bool {
+	return strings.EqualFold(strings.TrimSpace(header.Get("Content-Encoding")), "gzip")
+}
+
+// decompressRequest replaces a verified gzip body with the decompressed body, removing the
+// Content-Encoding so the handler reads it as any other request
+func decompressRequest(req *http.Request, body []byte) error {
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Body = ioutil.NopCloser(reader)
+	req.ContentLength = -1
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	return nil
+}
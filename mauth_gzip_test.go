@@ -0,0 +1,109 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test the compressed body can be rewound and compresses to the same bytes
+func TestGzipBody(t *testing.T) {
+	content := strings.Repeat("compressible content ", 10000)
+	body, _ := newGzipBody(strings.NewReader(content), gzip.DefaultCompression)
+	first, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+	if len(first) >= len(content) {
+		t.Error("Expected the body to be compressed")
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		t.Fatal("Unable to rewind ", err)
+	}
+	second, _ := ioutil.ReadAll(body)
+	if !bytes.Equal(first, second) {
+		t.Error("Expected the same compressed bytes after rewinding")
+	}
+	reader, _ := gzip.NewReader(bytes.NewReader(first))
+	if decompressed, _ := ioutil.ReadAll(reader); string(decompressed) != content {
+		t.Error("The decompressed body does not match")
+	}
+	if _, err := body.Seek(10, io.SeekStart); err == nil {
+		t.Error("Expected an error seeking within the compressed body")
+	}
+}
+
+// Test a compressed upload is verified and then decompressed for the handler
+func TestMAuthClient_EnableGzip(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	content := `{"records": "` + strings.Repeat("a", 10000) + `"}`
+	for _, decompress := range []bool{false, true} {
+		verifier := testVerifier(mauthApp)
+		verifier.DecompressGzip = decompress
+		var encoding, contentType string
+		var received []byte
+		var length int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			length = r.ContentLength
+			if _, err := verifier.VerifyRequest(r); err != nil {
+				t.Error("Verification failed: ", err)
+			}
+			encoding = r.Header.Get("Content-Encoding")
+			contentType = r.Header.Get("Content-Type")
+			received, _ = ioutil.ReadAll(r.Body)
+		}))
+		client, _ := mauthApp.CreateClient(server.URL)
+		if err := client.EnableGzip(gzip.BestCompression); err != nil {
+			t.Fatal("Unexpected error ", err)
+		}
+		_, err := client.Post("/api/v2/records", content)
+		server.Close()
+		if err != nil {
+			t.Fatal("Post Failed: ", err)
+		}
+		if length <= 0 || length >= int64(len(content)) {
+			t.Error("Expected the compressed Content-Length, got ", length)
+		}
+		if contentType != "application/json" {
+			t.Error("Expected the JSON Content-Type, got ", contentType)
+		}
+		if decompress {
+			if encoding != "" || string(received) != content {
+				t.Error("Expected the handler to read the decompressed body")
+			}
+		} else {
+			reader, _ := gzip.NewReader(bytes.NewReader(received))
+			decompressed, _ := ioutil.ReadAll(reader)
+			if encoding != "gzip" || string(decompressed) != content {
+				t.Error("Expected the handler to read the compressed body")
+			}
+		}
+	}
+}
+
+// Test requests without a body are sent uncompressed and an invalid level is rejected
+func TestMAuthClient_EnableGzipEmptyBody(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	var encoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+	}))
+	defer server.Close()
+	client, _ := mauthApp.CreateClient(server.URL)
+	if err := client.EnableGzip(42); err == nil {
+		t.Error("Expected an error for an invalid compression level")
+	}
+	_ = client.EnableGzip(gzip.DefaultCompression)
+	if _, err := client.Get("/api/v2/users.json"); err != nil {
+		t.Fatal("Get Failed: ", err)
+	}
+	if encoding != "" {
+		t.Error("Expected no Content-Encoding for an empty body, got ", encoding)
+	}
+}
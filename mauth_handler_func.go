@@ -1,7 +1,11 @@
 package go_mauth_client
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
 )
 
 /*
@@ -22,3 +26,43 @@ func isJSON(s string) bool {
 	var js json.RawMessage
 	return json.Unmarshal([]byte(s), &js) == nil
 }
+
+// jsonContentType returns "application/json" for a body which is JSON, otherwise no Content-Type
+func jsonContentType(body []byte) string {
+	if json.Valid(body) {
+		return "application/json"
+	}
+	return ""
+}
+
+// maxBodyPreallocation caps the buffer allocated up front for a body; the Content-Length of a
+// request being verified comes from an unauthenticated client, so it can't be trusted any further
+const maxBodyPreallocation = 1 << 20
+
+// readBody reads a body in full, allocating up front for its length when known, up to
+// maxBodyPreallocation
+func readBody(body io.Reader, contentLength int64) ([]byte, error) {
+	if contentLength <= 0 {
+		return ioutil.ReadAll(body)
+	}
+	if contentLength > maxBodyPreallocation {
+		contentLength = maxBodyPreallocation
+	}
+	buffer := bytes.NewBuffer(make([]byte, 0, contentLength+bytes.MinRead))
+	_, err := buffer.ReadFrom(body)
+	return buffer.Bytes(), err
+}
+
+// readAndRestoreBody reads the request body and replaces it with an equivalent reader
+func readAndRestoreBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return []byte{}, nil
+	}
+	body, err := readBody(req.Body, req.ContentLength)
+	if err != nil {
+		return nil, err
+	}
+	_ = req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
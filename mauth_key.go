@@ -0,0 +1,209 @@
+package go_mauth_client
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+/*
+Parsing of the private key formats accepted by LoadMauth, and generation of new keys
+*/
+
+var (
+	// ErrPassphraseRequired is returned when the private key is encrypted and no passphrase was given
+	ErrPassphraseRequired = errors.New("Private key is encrypted, a passphrase is required")
+	// ErrIncorrectPassphrase is returned when the passphrase doesn't decrypt the private key
+	ErrIncorrectPassphrase = errors.New("Incorrect passphrase for the private key")
+	// ErrNotRSAKey is returned when the private key is a valid key of another type
+	ErrNotRSAKey = errors.New("Private key is not an RSA key")
+	// ErrKeyTooSmall is returned when the private key is smaller than the minimum key size
+	ErrKeyTooSmall = errors.New("Private key is too small")
+	// ErrKeyMismatch is returned when the private key doesn't match the supplied public key
+	ErrKeyMismatch = errors.New("Private key does not match the public key")
+	// ErrInvalidAppId is returned when the App UUID is not a UUID
+	ErrInvalidAppId = errors.New("App UUID is not a valid UUID")
+)
+
+// DefaultMinKeySize is the smallest key size, in bits, accepted by LoadMauth unless configured otherwise
+const DefaultMinKeySize = 2048
+
+var appIdPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validateAppId checks the App UUID is in the canonical UUID form
+func validateAppId(appId string) error {
+	if !appIdPattern.MatchString(appId) {
+		return fmt.Errorf("%w: %q", ErrInvalidAppId, appId)
+	}
+	return nil
+}
+
+// validateKey applies the load time key policy: the minimum size, the consistency checks of
+// rsa.PrivateKey.Validate and, when a public key is supplied, that the two match
+func validateKey(privateKey *rsa.PrivateKey, options MAuthOptions) error {
+	minKeySize := options.MinKeySize
+	if minKeySize == 0 {
+		minKeySize = DefaultMinKeySize
+	}
+	if bits := privateKey.N.BitLen(); bits < minKeySize {
+		return fmt.Errorf("%w: %d bits, at least %d required", ErrKeyTooSmall, bits, minKeySize)
+	}
+	if err := privateKey.Validate(); err != nil {
+		return err
+	}
+	if options.PublicKey != "" {
+		publicKey, err := LoadPublicKey(options.PublicKey)
+		if err != nil {
+			return err
+		}
+		if !privateKey.PublicKey.Equal(publicKey) {
+			return ErrKeyMismatch
+		}
+	}
+	return nil
+}
+
+// parsePrivateKey reads an RSA private key from PEM, as PKCS#1 ("RSA PRIVATE KEY", optionally with
+// legacy OpenSSL encryption), PKCS#8 ("PRIVATE KEY") or encrypted PKCS#8 ("ENCRYPTED PRIVATE KEY"),
+// or from a DER encoded PKCS#12 bundle
+func parsePrivateKey(content []byte, passphrase string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		// PKCS#12 bundles are binary, and start with an ASN.1 SEQUENCE
+		if len(content) > 0 && content[0] == 0x30 {
+			return parsePKCS12(content, passphrase)
+		}
+		return nil, errors.New("Unable to extract PEM content")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		der := block.Bytes
+		// legacy encrypted PEM is still produced by `openssl genrsa -aes256`
+		if x509.IsEncryptedPEMBlock(block) {
+			if passphrase == "" {
+				return nil, ErrPassphraseRequired
+			}
+			var err error
+			der, err = x509.DecryptPEMBlock(block, []byte(passphrase))
+			if err == x509.IncorrectPasswordError {
+				return nil, ErrIncorrectPassphrase
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		privateKey, err := x509.ParsePKCS1PrivateKey(der)
+		if err != nil && block.Headers["DEK-Info"] != "" {
+			// a wrong passphrase doesn't always fail the padding check
+			return nil, ErrIncorrectPassphrase
+		}
+		return privateKey, err
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return asRSAPrivateKey(privateKey)
+	case "ENCRYPTED PRIVATE KEY":
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		privateKey, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(passphrase))
+		if err != nil && err.Error() == "pkcs8: incorrect password" {
+			return nil, ErrIncorrectPassphrase
+		}
+		if err != nil {
+			return nil, err
+		}
+		return asRSAPrivateKey(privateKey)
+	default:
+		return nil, fmt.Errorf("Unsupported PEM block type %q for a private key", block.Type)
+	}
+}
+
+// parsePKCS12 reads the private key from a PKCS#12 bundle, ignoring any certificates
+func parsePKCS12(content []byte, passphrase string) (*rsa.PrivateKey, error) {
+	privateKey, _, _, err := pkcs12.DecodeChain(content, passphrase)
+	if err == pkcs12.ErrIncorrectPassword {
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		return nil, ErrIncorrectPassphrase
+	}
+	if err != nil {
+		return nil, err
+	}
+	return asRSAPrivateKey(privateKey)
+}
+
+// asRSAPrivateKey checks a parsed private key is an RSA key
+func asRSAPrivateKey(privateKey interface{}) (*rsa.PrivateKey, error) {
+	rsaPrivateKey, ok := privateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrNotRSAKey
+	}
+	return rsaPrivateKey, nil
+}
+
+// GenerateKeyPair creates a new RSA key pair of the given size (in bits) for registering an App.
+// The private key is PKCS#1 PEM, as read by LoadMauth; the public key is PKIX ("PUBLIC KEY") PEM,
+// the format expected by the MAuth service.  Sizes below DefaultMinKeySize return ErrKeyTooSmall.
+func GenerateKeyPair(bits int) (privateKeyPEM string, publicKeyPEM string, err error) {
+	if bits < DefaultMinKeySize {
+		return "", "", fmt.Errorf("%w: %d bits, at least %d required", ErrKeyTooSmall, bits, DefaultMinKeySize)
+	}
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return "", "", err
+	}
+	privateKeyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}))
+	publicKeyPEM, err = encodePublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+	return privateKeyPEM, publicKeyPEM, nil
+}
+
+// encodePublicKey returns the PKIX PEM encoding of a public key
+func encodePublicKey(publicKey crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// PublicKeyPEM exports the App's public key as PKIX PEM, for registering with the MAuth service
+func (mauthApp *MAuthApp) PublicKeyPEM() (string, error) {
+	signer, err := mauthApp.signer()
+	if err != nil {
+		return "", err
+	}
+	return encodePublicKey(signer.Public())
+}
+
+// PublicKeyFingerprint returns the SHA-256 fingerprint of the App's public key, as the lowercase
+// hex encoding of the digest of its PKIX DER form (as `openssl pkey -pubout -outform DER | sha256sum`)
+func (mauthApp *MAuthApp) PublicKeyFingerprint() (string, error) {
+	signer, err := mauthApp.signer()
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(der)
+	return hex.EncodeToString(digest[:]), nil
+}
@@ -0,0 +1,144 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultReloadInterval is how often a ReloadingKey checks its source for a new key
+const DefaultReloadInterval = 10 * time.Second
+
+// ReloadOptions configures a ReloadingKey
+type ReloadOptions struct {
+	// Passphrase decrypts an encrypted key
+	Passphrase string
+	// Interval is how often the source is checked, DefaultReloadInterval if zero
+	Interval time.Duration
+	// MinKeySize is the smallest key size accepted, in bits; DefaultMinKeySize if zero
+	MinKeySize int
+	// OnReload is called with the new key after it has been swapped in
+	OnReload func(privateKey *rsa.PrivateKey)
+	// OnError is called when the source can't be read or parsed; the previous key stays in use
+	OnError func(err error)
+}
+
+// ReloadingKey is a crypto.Signer whose private key is reloaded when the content of its KeySource
+// changes, such as a mounted secret which is rotated in place.  Use it as the MAuthApp.Signer;
+// each signature uses the key current when it starts, so in flight requests are unaffected by a swap.
+type ReloadingKey struct {
+	source  KeySource
+	options ReloadOptions
+	key     atomic.Value // *rsa.PrivateKey
+	content []byte
+	mutex   sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewReloadingKey loads the key from source and starts checking it for changes; an error is
+// returned if the initial key can't be loaded.  Call Close to stop checking.
+func NewReloadingKey(source KeySource, options ReloadOptions) (*ReloadingKey, error) {
+	if options.Interval <= 0 {
+		options.Interval = DefaultReloadInterval
+	}
+	reloading := &ReloadingKey{source: source, options: options, stop: make(chan struct{}),
+		done: make(chan struct{})}
+	if _, err := reloading.Reload(); err != nil {
+		return nil, err
+	}
+	go reloading.watch()
+	return reloading, nil
+}
+
+// watch checks the source every interval until Close is called
+func (reloading *ReloadingKey) watch() {
+	defer close(reloading.done)
+	ticker := time.NewTicker(reloading.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-reloading.stop:
+			return
+		case <-ticker.C:
+			// a tick ready at the same time as Close doesn't reload
+			select {
+			case <-reloading.stop:
+				return
+			default:
+			}
+			changed, err := reloading.Reload()
+			if err != nil && reloading.options.OnError != nil {
+				reloading.options.OnError(err)
+			}
+			if changed && reloading.options.OnReload != nil {
+				reloading.options.OnReload(reloading.PrivateKey())
+			}
+		}
+	}
+}
+
+// Reload reads the source and swaps in the key if the content has changed, reporting whether it did.
+// The new key must pass the checks LoadMauth applies; on error the previous key is kept.
+func (reloading *ReloadingKey) Reload() (changed bool, err error) {
+	reloading.mutex.Lock()
+	defer reloading.mutex.Unlock()
+	content, err := reloading.source.ReadKey()
+	if err != nil {
+		return false, &KeySourceError{Source: reloading.source.String(), Err: err}
+	}
+	if reloading.content != nil && bytes.Equal(content, reloading.content) {
+		return false, nil
+	}
+	privateKey, err := parsePrivateKey(content, reloading.options.Passphrase)
+	if err != nil {
+		return false, &KeySourceError{Source: reloading.source.String(), Err: err}
+	}
+	if err := validateKey(privateKey, MAuthOptions{MinKeySize: reloading.options.MinKeySize}); err != nil {
+		return false, &KeySourceError{Source: reloading.source.String(), Err: err}
+	}
+	privateKey.Precompute()
+	reloading.key.Store(privateKey)
+	reloading.content = content
+	return true, nil
+}
+
+// PrivateKey returns the key currently in use
+func (reloading *ReloadingKey) PrivateKey() *rsa.PrivateKey {
+	return reloading.key.Load().(*rsa.PrivateKey)
+}
+
+// Public returns the public key of the key currently in use
+func (reloading *ReloadingKey) Public() crypto.PublicKey {
+	return reloading.PrivateKey().Public()
+}
+
+// Sign signs with the key currently in use
+func (reloading *ReloadingKey) Sign(random io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return reloading.PrivateKey().Sign(random, digest, opts)
+}
+
+// Close stops checking the source for changes, returning once any check in progress has finished;
+// the current key can still be used.  It must not be called from OnReload or OnError.
+func (reloading *ReloadingKey) Close() error {
+	reloading.once.Do(func() {
+		close(reloading.stop)
+	})
+	<-reloading.done
+	return nil
+}
+
+// Close stops the key reloading LoadMauth starts for the ReloadKey option, as ReloadingKey.Close
+// does; the App can still sign with the current key.  Closing an App which doesn't sign through a
+// ReloadingKey does nothing.
+func (mauthApp *MAuthApp) Close() error {
+	if reloading, ok := mauthApp.Signer.(*ReloadingKey); ok {
+		return reloading.Close()
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package go_mauth_client
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestKey writes a private key as PKCS#1 PEM
+func writeTestKey(t *testing.T, path string, privateKey *rsa.PrivateKey) {
+	content := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal("Unable to write key: ", err)
+	}
+}
+
+// reloadingTestKey creates a ReloadingKey on a copy of the test key in a temporary folder
+func reloadingTestKey(t *testing.T, options ReloadOptions) (*ReloadingKey, string, *MAuthApp) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	dir, err := ioutil.TempDir("", "mauth-reload")
+	if err != nil {
+		t.Fatal("Unable to create key directory: ", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "private_key.pem")
+	writeTestKey(t, path, mauthApp.RsaPrivateKey)
+	reloading, err := NewReloadingKey(KeyFromFile(path), options)
+	if err != nil {
+		t.Fatal("Unable to load reloading key: ", err)
+	}
+	t.Cleanup(func() { _ = reloading.Close() })
+	return reloading, path, mauthApp
+}
+
+func TestReloadingKey_Sign(t *testing.T) {
+	reloading, _, mauthApp := reloadingTestKey(t, ReloadOptions{})
+	expected, _ := SignStringV2(mauthApp, "Hello world")
+	actual, err := SignStringV2(&MAuthApp{AppId: app_id, Signer: reloading}, "Hello world")
+	if err != nil {
+		t.Error("Error signing: ", err)
+	}
+	if actual != expected {
+		t.Error("Signature does not match: ", actual)
+	}
+}
+
+// Test the key is swapped when the file is rotated
+func TestReloadingKey_Rotate(t *testing.T) {
+	reloaded := make(chan *rsa.PrivateKey, 1)
+	reloading, path, mauthApp := reloadingTestKey(t, ReloadOptions{Interval: 10 * time.Millisecond,
+		OnReload: func(privateKey *rsa.PrivateKey) { reloaded <- privateKey }})
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writeTestKey(t, path, newKey)
+	select {
+	case privateKey := <-reloaded:
+		if privateKey.N.Cmp(newKey.N) != 0 {
+			t.Error("Reloaded key doesn't match the rotated key")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Key was not reloaded")
+	}
+	app := &MAuthApp{AppId: app_id, Signer: reloading}
+	req, _ := app.makeRequest("GET", "https://innovate.mdsol.com/api/v2/users.json", "", map[string][]string{})
+	if _, err := testVerifier(mauthApp).VerifyRequest(req); err != ErrInvalidSignature {
+		t.Error("Expected the old public key to be rejected, got ", err)
+	}
+	verifier := NewVerifier(StaticKeyProvider{app_id: &newKey.PublicKey})
+	if _, err := verifier.VerifyRequest(req); err != nil {
+		t.Error("Request did not verify with the new key: ", err)
+	}
+}
+
+// Test a broken file keeps the previous key and reports the error
+func TestReloadingKey_ParseError(t *testing.T) {
+	failed := make(chan error, 1)
+	reloading, path, mauthApp := reloadingTestKey(t, ReloadOptions{Interval: 10 * time.Millisecond,
+		OnError: func(err error) {
+			select {
+			case failed <- err:
+			default:
+			}
+		}})
+	if err := ioutil.WriteFile(path, []byte("Platypus"), 0600); err != nil {
+		t.Fatal("Unable to write key: ", err)
+	}
+	select {
+	case <-failed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Error was not reported")
+	}
+	if reloading.PrivateKey().N.Cmp(mauthApp.RsaPrivateKey.N) != 0 {
+		t.Error("Previous key was not kept")
+	}
+}
+
+// Test a rotated key is held to the load time policy
+func TestReloadingKey_ReloadValidates(t *testing.T) {
+	reloading, path, mauthApp := reloadingTestKey(t, ReloadOptions{})
+	smallKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	writeTestKey(t, path, smallKey)
+	if _, err := reloading.Reload(); !errors.Is(err, ErrKeyTooSmall) {
+		t.Error("Expected ErrKeyTooSmall for a 1024 bit key, got ", err)
+	}
+	inconsistent, _ := rsa.GenerateKey(rand.Reader, 2048)
+	inconsistent.D.Add(inconsistent.D, big.NewInt(2))
+	writeTestKey(t, path, inconsistent)
+	if _, err := reloading.Reload(); err == nil {
+		t.Error("Expected an error for an inconsistent key")
+	}
+	if reloading.PrivateKey().N.Cmp(mauthApp.RsaPrivateKey.N) != 0 {
+		t.Error("Previous key was not kept")
+	}
+}
+
+func TestReloadingKey_Reload(t *testing.T) {
+	reloading, _, _ := reloadingTestKey(t, ReloadOptions{})
+	changed, err := reloading.Reload()
+	if err != nil {
+		t.Error("Error reloading: ", err)
+	}
+	if changed {
+		t.Error("Expected unchanged content not to be reloaded")
+	}
+}
+
+// Test LoadMauth signs through a ReloadingKey when asked to reload the key
+func TestLoadMauthReloadKey(t *testing.T) {
+	_, path, mauthApp := reloadingTestKey(t, ReloadOptions{})
+	app, err := LoadMauth(MAuthOptions{AppId: app_id, KeySource: KeyFromFile(path), SignVersions: []SignVersion{SignV2},
+		ReloadKey: &ReloadOptions{}})
+	if err != nil {
+		t.Fatal("Error loading App: ", err)
+	}
+	reloading, ok := app.Signer.(*ReloadingKey)
+	if !ok {
+		t.Fatal("Expected the App to sign through a ReloadingKey, got ", app.Signer)
+	}
+	defer reloading.Close()
+	if app.RsaPrivateKey != nil || !app.DisableV1 {
+		t.Error("Unexpected App ", app)
+	}
+	req, _ := app.makeRequest("GET", "https://innovate.mdsol.com/api/v2/users.json", "", map[string][]string{})
+	if _, err := testVerifier(mauthApp).VerifyRequest(req); err != nil {
+		t.Error("Request did not verify: ", err)
+	}
+	if _, err := LoadMauth(MAuthOptions{AppId: "banana", KeySource: KeyFromFile(path), ReloadKey: &ReloadOptions{}}); err == nil {
+		t.Error("Expected error for an invalid App UUID")
+	}
+	_, err = LoadMauth(MAuthOptions{AppId: app_id, KeySource: KeyFromFile(path), MinKeySize: 4096,
+		ReloadKey: &ReloadOptions{}})
+	if !errors.Is(err, ErrKeyTooSmall) {
+		t.Error("Expected ErrKeyTooSmall with MinKeySize 4096, got ", err)
+	}
+}
+
+// Test closing the App stops the reloading LoadMauth started, keeping the current key
+func TestMAuthApp_Close(t *testing.T) {
+	reloaded := make(chan *rsa.PrivateKey, 2)
+	_, path, mauthApp := reloadingTestKey(t, ReloadOptions{})
+	app, err := LoadMauth(MAuthOptions{AppId: app_id, KeySource: KeyFromFile(path), ReloadKey: &ReloadOptions{
+		Interval: 10 * time.Millisecond, OnReload: func(privateKey *rsa.PrivateKey) { reloaded <- privateKey }}})
+	if err != nil {
+		t.Fatal("Error loading App: ", err)
+	}
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writeTestKey(t, path, newKey)
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Key was not reloaded before closing")
+	}
+	if err := app.Close(); err != nil {
+		t.Error("Unexpected error closing the App: ", err)
+	}
+	if err := app.Close(); err != nil {
+		t.Error("Unexpected error closing the App twice: ", err)
+	}
+	writeTestKey(t, path, mauthApp.RsaPrivateKey)
+	select {
+	case <-reloaded:
+		t.Error("Key was reloaded after closing")
+	case <-time.After(100 * time.Millisecond):
+	}
+	req, _ := app.makeRequest("GET", "https://innovate.mdsol.com/api/v2/users.json", "", map[string][]string{})
+	verifier := NewVerifier(StaticKeyProvider{app_id: &newKey.PublicKey})
+	if _, err := verifier.VerifyRequest(req); err != nil {
+		t.Error("Expected the closed App to sign with the current key: ", err)
+	}
+	if err := mauthApp.Close(); err != nil {
+		t.Error("Unexpected error closing an App without reloading: ", err)
+	}
+}
+
+func TestNewReloadingKeyMissing(t *testing.T) {
+	_, err := NewReloadingKey(KeyFromFile(filepath.Join("test", "banana.pem")), ReloadOptions{})
+	if err == nil {
+		t.Error("Expected error loading a missing key")
+	}
+}
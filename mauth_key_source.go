@@ -0,0 +1,198 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+/*
+Explicit sources for the private key content, used through MAuthOptions.KeySource
+*/
+
+// KeySource supplies the content of a private key, in any format accepted by LoadMauth
+type KeySource interface {
+	// ReadKey returns the key content
+	ReadKey() ([]byte, error)
+	// String describes the source, for error messages
+	String() string
+}
+
+// KeySourceError reports the KeySource which failed to supply a usable key
+type KeySourceError struct {
+	Source string
+	Err    error
+}
+
+func (e *KeySourceError) Error() string {
+	return e.Source + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *KeySourceError) Unwrap() error {
+	return e.Err
+}
+
+type fileKeySource struct {
+	path string
+}
+
+// KeyFromFile reads the key from a file
+func KeyFromFile(path string) KeySource {
+	return &fileKeySource{path: path}
+}
+
+func (source *fileKeySource) ReadKey() ([]byte, error) {
+	return ioutil.ReadFile(source.path)
+}
+
+func (source *fileKeySource) String() string {
+	return fmt.Sprintf("private key file %s", source.path)
+}
+
+type pemKeySource struct {
+	content string
+}
+
+// KeyFromPEM uses the PEM content directly
+func KeyFromPEM(content string) KeySource {
+	return &pemKeySource{content: content}
+}
+
+func (source *pemKeySource) ReadKey() ([]byte, error) {
+	if strings.TrimSpace(source.content) == "" {
+		return nil, errors.New("PEM content is empty")
+	}
+	return []byte(source.content), nil
+}
+
+func (source *pemKeySource) String() string {
+	return "private key PEM"
+}
+
+type envKeySource struct {
+	name string
+}
+
+// KeyFromEnv reads the key from an environment variable
+func KeyFromEnv(name string) KeySource {
+	return &envKeySource{name: name}
+}
+
+func (source *envKeySource) ReadKey() ([]byte, error) {
+	value, exists := os.LookupEnv(source.name)
+	if !exists {
+		return nil, errors.New("environment variable is not set")
+	}
+	if strings.TrimSpace(value) == "" {
+		return nil, errors.New("environment variable is empty")
+	}
+	return []byte(value), nil
+}
+
+func (source *envKeySource) String() string {
+	return fmt.Sprintf("environment variable %s", source.name)
+}
+
+type fsKeySource struct {
+	fsys fs.FS
+	name string
+}
+
+// KeyFromFS reads the key from a file in fsys, such as an embed.FS
+func KeyFromFS(fsys fs.FS, name string) KeySource {
+	return &fsKeySource{fsys: fsys, name: name}
+}
+
+func (source *fsKeySource) ReadKey() ([]byte, error) {
+	return fs.ReadFile(source.fsys, source.name)
+}
+
+func (source *fsKeySource) String() string {
+	return fmt.Sprintf("private key file %s in %T", source.name, source.fsys)
+}
+
+type base64KeySource struct {
+	source KeySource
+}
+
+// Base64Encoded decodes the content of another source, for keys stored base64 encoded,
+// e.g. Base64Encoded(KeyFromEnv("MAUTH_PRIVATE_KEY"))
+func Base64Encoded(source KeySource) KeySource {
+	return &base64KeySource{source: source}
+}
+
+// KeyFromBase64 uses base64 encoded key content, as Kubernetes secrets are supplied
+func KeyFromBase64(encoded string) KeySource {
+	return Base64Encoded(KeyFromPEM(encoded))
+}
+
+func (source *base64KeySource) ReadKey() ([]byte, error) {
+	encoded, err := source.source.ReadKey()
+	if err != nil {
+		return nil, err
+	}
+	// tolerate the trailing newline and line wrapping of `base64` output
+	encoded = bytes.Join(bytes.Fields(encoded), nil)
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+	n, err := base64.StdEncoding.Decode(decoded, encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 content: %v", err)
+	}
+	return decoded[:n], nil
+}
+
+func (source *base64KeySource) String() string {
+	return fmt.Sprintf("base64 encoded %s", source.source)
+}
+
+type firstKeySource struct {
+	sources []KeySource
+}
+
+// FirstKey uses the first of the sources which supplies a key, e.g. a mounted file with a fallback
+// to an environment variable
+func FirstKey(sources ...KeySource) KeySource {
+	return &firstKeySource{sources: sources}
+}
+
+func (source *firstKeySource) ReadKey() ([]byte, error) {
+	failures := []string{}
+	for _, candidate := range source.sources {
+		content, err := candidate.ReadKey()
+		if err == nil {
+			return content, nil
+		}
+		failures = append(failures, (&KeySourceError{Source: candidate.String(), Err: err}).Error())
+	}
+	return nil, fmt.Errorf("no key available (%s)", strings.Join(failures, "; "))
+}
+
+func (source *firstKeySource) String() string {
+	descriptions := []string{}
+	for _, candidate := range source.sources {
+		descriptions = append(descriptions, candidate.String())
+	}
+	return fmt.Sprintf("first of [%s]", strings.Join(descriptions, ", "))
+}
+
+// loadKey reads and parses the private key from source, reporting the source on failure
+func loadKey(source KeySource, passphrase string) (*rsa.PrivateKey, error) {
+	content, err := source.ReadKey()
+	if err != nil {
+		return nil, &KeySourceError{Source: source.String(), Err: err}
+	}
+	privateKey, err := parsePrivateKey(content, passphrase)
+	if err != nil {
+		return nil, &KeySourceError{Source: source.String(), Err: err}
+	}
+	// the CRT values make each signature several times faster; parsers usually set them already
+	privateKey.Precompute()
+	return privateKey, nil
+}
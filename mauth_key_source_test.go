@@ -0,0 +1,120 @@
+package go_mauth_client
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// loadFromSource loads the test App with the given KeySource
+func loadFromSource(source KeySource) (*MAuthApp, error) {
+	return LoadMauth(MAuthOptions{AppId: app_id, KeySource: source})
+}
+
+func TestKeyFromFile(t *testing.T) {
+	if _, err := loadFromSource(KeyFromFile(filepath.Join("test", "private_key.pem"))); err != nil {
+		t.Error("Error loading key from file: ", err)
+	}
+	_, err := loadFromSource(KeyFromFile(filepath.Join("test", "banana.pem")))
+	if !os.IsNotExist(errors.Unwrap(err)) {
+		t.Error("Expected the missing file to be reported, got ", err)
+	}
+	if !strings.Contains(err.Error(), "banana.pem") {
+		t.Error("Expected the path in the error, got ", err)
+	}
+}
+
+func TestKeyFromPEM(t *testing.T) {
+	content, _ := ioutil.ReadFile(filepath.Join("test", "private_key.pem"))
+	if _, err := loadFromSource(KeyFromPEM(string(content))); err != nil {
+		t.Error("Error loading key from PEM: ", err)
+	}
+	if _, err := loadFromSource(KeyFromPEM("")); err == nil {
+		t.Error("Expected error with empty PEM content")
+	}
+}
+
+func TestKeyFromBase64(t *testing.T) {
+	content, _ := ioutil.ReadFile(filepath.Join("test", "private_key.pem"))
+	encoded := base64.StdEncoding.EncodeToString(content) + "\n"
+	if _, err := loadFromSource(KeyFromBase64(encoded)); err != nil {
+		t.Error("Error loading key from base64: ", err)
+	}
+	_, err := loadFromSource(KeyFromBase64("not*base64"))
+	if err == nil || !strings.Contains(err.Error(), "invalid base64") {
+		t.Error("Expected invalid base64 to be reported, got ", err)
+	}
+}
+
+func TestKeyFromEnv(t *testing.T) {
+	content, _ := ioutil.ReadFile(filepath.Join("test", "private_key.pem"))
+	_ = os.Setenv("MAUTH_TEST_PRIVATE_KEY", string(content))
+	_ = os.Setenv("MAUTH_TEST_PRIVATE_KEY_B64", base64.StdEncoding.EncodeToString(content))
+	_ = os.Setenv("MAUTH_TEST_EMPTY", "")
+	defer func() {
+		_ = os.Unsetenv("MAUTH_TEST_PRIVATE_KEY")
+		_ = os.Unsetenv("MAUTH_TEST_PRIVATE_KEY_B64")
+		_ = os.Unsetenv("MAUTH_TEST_EMPTY")
+	}()
+	if _, err := loadFromSource(KeyFromEnv("MAUTH_TEST_PRIVATE_KEY")); err != nil {
+		t.Error("Error loading key from environment: ", err)
+	}
+	if _, err := loadFromSource(Base64Encoded(KeyFromEnv("MAUTH_TEST_PRIVATE_KEY_B64"))); err != nil {
+		t.Error("Error loading base64 key from environment: ", err)
+	}
+	_, err := loadFromSource(KeyFromEnv("MAUTH_TEST_MISSING"))
+	if err == nil || err.Error() != "environment variable MAUTH_TEST_MISSING: environment variable is not set" {
+		t.Error("Expected unset variable to be reported, got ", err)
+	}
+	_, err = loadFromSource(KeyFromEnv("MAUTH_TEST_EMPTY"))
+	if err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Error("Expected empty variable to be reported, got ", err)
+	}
+}
+
+func TestKeyFromFS(t *testing.T) {
+	content, _ := ioutil.ReadFile(filepath.Join("test", "private_key.pem"))
+	fsys := fstest.MapFS{"keys/private_key.pem": &fstest.MapFile{Data: content}}
+	if _, err := loadFromSource(KeyFromFS(fsys, "keys/private_key.pem")); err != nil {
+		t.Error("Error loading key from fs.FS: ", err)
+	}
+	if _, err := loadFromSource(KeyFromFS(fsys, "keys/missing.pem")); err == nil {
+		t.Error("Expected error with missing file in fs.FS")
+	}
+}
+
+func TestFirstKey(t *testing.T) {
+	source := FirstKey(KeyFromEnv("MAUTH_TEST_MISSING"), KeyFromFile(filepath.Join("test", "private_key.pem")))
+	if _, err := loadFromSource(source); err != nil {
+		t.Error("Error loading key from second source: ", err)
+	}
+	_, err := loadFromSource(FirstKey(KeyFromEnv("MAUTH_TEST_MISSING"), KeyFromFile("banana.pem")))
+	if err == nil || !strings.Contains(err.Error(), "MAUTH_TEST_MISSING") || !strings.Contains(err.Error(), "banana.pem") {
+		t.Error("Expected every failure to be reported, got ", err)
+	}
+}
+
+// Test a key which can be read but not parsed reports the source
+func TestKeySourceParseError(t *testing.T) {
+	_, err := loadFromSource(KeyFromFile(filepath.Join("test", "junk.pem")))
+	var sourceErr *KeySourceError
+	if !errors.As(err, &sourceErr) {
+		t.Fatal("Expected a KeySourceError, got ", err)
+	}
+	if sourceErr.Source != "private key file "+filepath.Join("test", "junk.pem") {
+		t.Error("Unexpected source: ", sourceErr.Source)
+	}
+}
+
+// Test a mistyped path is reported as a missing file, not as bad PEM content
+func TestLoadMauthMissingFileError(t *testing.T) {
+	_, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "banana.pem")})
+	if !os.IsNotExist(errors.Unwrap(err)) {
+		t.Error("Expected the missing file to be reported, got ", err)
+	}
+}
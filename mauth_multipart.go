@@ -0,0 +1,214 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+Signed multipart/form-data uploads, with the files streamed from disk
+*/
+
+// MultipartFile is a file to upload as part of a multipart form
+type MultipartFile struct {
+	// FieldName is the form field the file is sent as
+	FieldName string
+	// Path is the file on disk
+	Path string
+	// FileName is the name sent for the file, the base of Path if empty
+	FileName string
+	// ContentType is the Content-Type of the part, application/octet-stream if empty
+	ContentType string
+}
+
+// MAuthClient.PostMultipart executes a POST request against a targetURL with a multipart/form-data body
+// made from the fields and files.  The files are read from disk as the body is hashed and sent.
+func (mauthClient *MAuthClient) PostMultipart(targetURL string, fields map[string]string,
+	files []MultipartFile) (response *http.Response, err error) {
+	return mauthClient.doMultipart("POST", targetURL, fields, files)
+}
+
+// MAuthClient.PutMultipart executes a PUT request against a targetURL with a multipart/form-data body,
+// as PostMultipart does
+func (mauthClient *MAuthClient) PutMultipart(targetURL string, fields map[string]string,
+	files []MultipartFile) (response *http.Response, err error) {
+	return mauthClient.doMultipart("PUT", targetURL, fields, files)
+}
+
+// doMultipart builds the multipart body and sends it with the boundary in the Content-Type
+func (mauthClient *MAuthClient) doMultipart(method string, targetURL string, fields map[string]string,
+	files []MultipartFile) (response *http.Response, err error) {
+	body, contentType, err := newMultipartBody(fields, files, mauthClient.mauthApp.random())
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return mauthClient.do(method, targetURL, body, contentType)
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// newMultipartBody lays out the multipart body as the part headers, held in memory, and the open
+// files between them.  The fields are written in name order and the boundary is read from random,
+// so the body is the same for the same form and randomness.
+func newMultipartBody(fields map[string]string, files []MultipartFile, random io.Reader) (body *multiReadSeeker,
+	contentType string, err error) {
+	body = &multiReadSeeker{}
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	boundary := make([]byte, 30)
+	if _, err := io.ReadFull(random, boundary); err != nil {
+		return nil, "", err
+	}
+	if err := writer.SetBoundary(fmt.Sprintf("%x", boundary)); err != nil {
+		return nil, "", err
+	}
+
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writer.WriteField(name, fields[name]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, file := range files {
+		if file.FieldName == "" {
+			body.Close()
+			return nil, "", errors.New("Multipart file needs a field name")
+		}
+		fileName := file.FileName
+		if fileName == "" {
+			fileName = filepath.Base(file.Path)
+		}
+		partContentType := file.ContentType
+		if partContentType == "" {
+			partContentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(file.FieldName), quoteEscaper.Replace(fileName)))
+		header.Set("Content-Type", partContentType)
+		if _, err := writer.CreatePart(header); err != nil {
+			body.Close()
+			return nil, "", err
+		}
+		// the part headers written so far come before the file content
+		body.add(bytes.NewReader(append([]byte(nil), buffer.Bytes()...)), int64(buffer.Len()), nil)
+		buffer.Reset()
+
+		opened, err := os.Open(file.Path)
+		if err != nil {
+			body.Close()
+			return nil, "", err
+		}
+		info, err := opened.Stat()
+		if err != nil {
+			_ = opened.Close()
+			body.Close()
+			return nil, "", err
+		}
+		body.add(opened, info.Size(), opened)
+	}
+
+	if err := writer.Close(); err != nil {
+		body.Close()
+		return nil, "", err
+	}
+	body.add(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()), nil)
+	return body, writer.FormDataContentType(), nil
+}
+
+// multiReadSeeker is the concatenation of several io.ReadSeekers, which can itself be rewound
+type multiReadSeeker struct {
+	segments []io.ReadSeeker
+	sizes    []int64
+	closers  []io.Closer
+	offset   int64
+}
+
+// add appends a segment of the given size; closer, if not nil, is closed with the multiReadSeeker
+func (reader *multiReadSeeker) add(segment io.ReadSeeker, size int64, closer io.Closer) {
+	reader.segments = append(reader.segments, segment)
+	reader.sizes = append(reader.sizes, size)
+	if closer != nil {
+		reader.closers = append(reader.closers, closer)
+	}
+}
+
+// size returns the total length of the segments
+func (reader *multiReadSeeker) size() int64 {
+	total := int64(0)
+	for _, size := range reader.sizes {
+		total += size
+	}
+	return total
+}
+
+// Read reads from the segment holding the current offset
+func (reader *multiReadSeeker) Read(p []byte) (int, error) {
+	start := int64(0)
+	for i, segment := range reader.segments {
+		end := start + reader.sizes[i]
+		if reader.offset < end {
+			if _, err := segment.Seek(reader.offset-start, io.SeekStart); err != nil {
+				return 0, err
+			}
+			if remaining := end - reader.offset; int64(len(p)) > remaining {
+				p = p[:remaining]
+			}
+			n, err := segment.Read(p)
+			reader.offset += int64(n)
+			if err == io.EOF {
+				if n == 0 {
+					return 0, io.ErrUnexpectedEOF
+				}
+				err = nil
+			}
+			return n, err
+		}
+		start = end
+	}
+	return 0, io.EOF
+}
+
+// Seek moves the offset within the concatenated segments
+func (reader *multiReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += reader.offset
+	case io.SeekEnd:
+		offset += reader.size()
+	default:
+		return 0, errors.New("Invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("Negative position")
+	}
+	reader.offset = offset
+	return offset, nil
+}
+
+// Close closes the files among the segments
+func (reader *multiReadSeeker) Close() error {
+	var err error
+	for _, closer := range reader.closers {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
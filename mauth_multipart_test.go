@@ -0,0 +1,89 @@
+package go_mauth_client
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test a multipart upload is signed and the form can be parsed by the server
+func TestMAuthClient_PostMultipart(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	verifier := testVerifier(mauthApp)
+	directory, _ := ioutil.TempDir("", "mauth-test-")
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "protocol.pdf")
+	content := strings.Repeat("%PDF-1.4 document content\n", 10000)
+	_ = ioutil.WriteFile(path, []byte(content), 0600)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifier.VerifyRequest(r); err != nil {
+			t.Error("Verification failed: ", err)
+		}
+		if r.ContentLength <= int64(len(content)) {
+			t.Error("Expected the Content-Length of the whole form, got ", r.ContentLength)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal("Unable to parse the form: ", err)
+		}
+		if r.FormValue("study") != "Mediflex" || r.FormValue("site") != "001" {
+			t.Error("Unexpected fields ", r.MultipartForm.Value)
+		}
+		file, header, err := r.FormFile("document")
+		if err != nil {
+			t.Fatal("Missing file: ", err)
+		}
+		defer file.Close()
+		if header.Filename != "protocol.pdf" || header.Header.Get("Content-Type") != "application/pdf" {
+			t.Error("Unexpected file header ", header.Header)
+		}
+		if received, _ := ioutil.ReadAll(file); string(received) != content {
+			t.Error("The file received does not match")
+		}
+	}))
+	defer server.Close()
+	client, _ := mauthApp.CreateClient(server.URL)
+	response, err := client.PostMultipart("/api/v2/documents", map[string]string{"study": "Mediflex", "site": "001"},
+		[]MultipartFile{{FieldName: "document", Path: path, ContentType: "application/pdf"}})
+	if err != nil {
+		t.Fatal("PostMultipart Failed: ", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Error("Expected 200, got ", response.StatusCode)
+	}
+}
+
+// Test a missing file is reported before anything is sent
+func TestMAuthClient_PostMultipartMissingFile(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	client, _ := mauthApp.CreateClient("https://innovate.mdsol.com")
+	_, err := client.PostMultipart("/api/v2/documents", nil,
+		[]MultipartFile{{FieldName: "document", Path: filepath.Join("test", "missing.pdf")}})
+	if !os.IsNotExist(err) {
+		t.Error("Expected a missing file error, got ", err)
+	}
+}
+
+// Test the concatenated segments read and rewind as a single body
+func TestMultiReadSeeker(t *testing.T) {
+	reader := &multiReadSeeker{}
+	for _, segment := range []string{"abc", "", "defg", "h"} {
+		reader.add(strings.NewReader(segment), int64(len(segment)), nil)
+	}
+	for i := 0; i < 2; i++ {
+		read, err := ioutil.ReadAll(reader)
+		if err != nil || string(read) != "abcdefgh" {
+			t.Errorf("Expected abcdefgh, got %q, %v", read, err)
+		}
+		_, _ = reader.Seek(0, io.SeekStart)
+	}
+	_, _ = reader.Seek(-3, io.SeekEnd)
+	if read, _ := ioutil.ReadAll(reader); string(read) != "fgh" {
+		t.Errorf("Expected fgh, got %q", read)
+	}
+}
@@ -0,0 +1,84 @@
+package go_mauth_client
+
+import (
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sync"
+)
+
+/*
+Pools for the signing hot path, so signing many requests doesn't allocate a hasher and copy buffer
+for each one
+*/
+
+// copyBufferSize is the size of the buffers used to stream bodies through the hashers
+const copyBufferSize = 32 * 1024
+
+var (
+	sha512Pool = sync.Pool{New: func() interface{} {
+		return sha512.New()
+	}}
+	copyBufferPool = sync.Pool{New: func() interface{} {
+		buffer := make([]byte, copyBufferSize)
+		return &buffer
+	}}
+)
+
+// getSHA512 returns a reset SHA-512 hasher from the pool
+func getSHA512() hash.Hash {
+	hasher := sha512Pool.Get().(hash.Hash)
+	hasher.Reset()
+	return hasher
+}
+
+// putSHA512 returns a hasher to the pool
+func putSHA512(hasher hash.Hash) {
+	sha512Pool.Put(hasher)
+}
+
+// sumHex returns the hex encoded sum of the hasher, without allocating beyond the string itself
+func sumHex(hasher hash.Hash) string {
+	var sum [sha512.Size]byte
+	var encoded [sha512.Size * 2]byte
+	hex.Encode(encoded[:], hasher.Sum(sum[:0]))
+	return string(encoded[:])
+}
+
+// copyPooled copies src to dst through a buffer from the pool
+func copyPooled(dst io.Writer, src io.Reader) (int64, error) {
+	buffer := copyBufferPool.Get().(*[]byte)
+	defer copyBufferPool.Put(buffer)
+	return io.CopyBuffer(dst, src, *buffer)
+}
+
+// hashHex returns the hex encoded SHA-512 of data
+func hashHex(data []byte) string {
+	var encoded [sha512.Size * 2]byte
+	sum := sha512.Sum512(data)
+	hex.Encode(encoded[:], sum[:])
+	return string(encoded[:])
+}
+
+var (
+	// precomputedKeys holds the keys known to be precomputed, so signing checks a key without a lock
+	precomputedKeys sync.Map // *rsa.PrivateKey -> struct{}
+	precomputeMutex sync.Mutex
+)
+
+// precompute makes sure a key has its CRT values precomputed before it signs.  LoadMauth precomputes
+// the keys it loads but an App built by hand may not have; Precompute writes to the key, so it runs
+// once per key under a lock.
+func precompute(privateKey *rsa.PrivateKey) {
+	if _, done := precomputedKeys.Load(privateKey); done {
+		return
+	}
+	precomputeMutex.Lock()
+	defer precomputeMutex.Unlock()
+	if _, done := precomputedKeys.Load(privateKey); !done {
+		privateKey.Precompute()
+		precomputedKeys.Store(privateKey, struct{}{})
+	}
+}
@@ -0,0 +1,237 @@
+package go_mauth_client
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+Signature schemes: the signing algorithm and header layout of a protocol version.  The V1 and V2
+schemes are built in; others, such as an experimental RSA-PSS or Ed25519 version, can be registered
+and then signed with by naming them in SignVersions, and are verified by any MAuthVerifier.
+*/
+
+// SignatureScheme is the signing algorithm and headers of a protocol version.
+// A registered scheme signs the V2 string to sign, and is sent as "<token> <app_uuid>:<signature>;"
+// in its authentication header, with the signing time in its time header.
+type SignatureScheme interface {
+	// Version names the scheme in SignVersions and MAUTH_SIGN_VERSIONS; it must be lower case
+	Version() SignVersion
+	// Token starts the authentication header value, e.g. "MWSV2"
+	Token() string
+	// AuthenticationHeader is the name of the header holding the signature
+	AuthenticationHeader() string
+	// TimeHeader is the name of the header holding the signing time
+	TimeHeader() string
+	// Sign signs the string to sign with the App's signer
+	Sign(signer crypto.Signer, random io.Reader, stringToSign string) ([]byte, error)
+	// Verify checks the signature of the string to sign against the App's public key
+	Verify(publicKey crypto.PublicKey, stringToSign string, signature []byte) error
+}
+
+// SchemeKeyProvider can be implemented by a PublicKeyProvider to supply keys other than RSA keys,
+// for registered schemes which use them
+type SchemeKeyProvider interface {
+	SchemePublicKey(appId string, version SignVersion) (crypto.PublicKey, error)
+}
+
+// rsaScheme is the RSA PKCS#1 v1.5 signing of the built in protocol versions.  A hash of 0 is V1,
+// which signs the hex encoded SHA-512 digest without a hash OID, and ends its authentication
+// header without the ";" of the later versions.
+type rsaScheme struct {
+	version              SignVersion
+	token                string
+	authenticationHeader string
+	timeHeader           string
+	hash                 crypto.Hash
+	terminator           string
+}
+
+var (
+	schemeV1 = &rsaScheme{SignV1, "MWS", "X-MWS-Authentication", "X-MWS-Time", crypto.Hash(0), ""}
+	schemeV2 = &rsaScheme{SignV2, "MWSV2", "MCC-Authentication", "MCC-Time", crypto.SHA512, ";"}
+)
+
+func (scheme *rsaScheme) Version() SignVersion         { return scheme.version }
+func (scheme *rsaScheme) Token() string                { return scheme.token }
+func (scheme *rsaScheme) AuthenticationHeader() string { return scheme.authenticationHeader }
+func (scheme *rsaScheme) TimeHeader() string           { return scheme.timeHeader }
+
+// digest returns what is signed for the SHA-512 of the string to sign
+func (scheme *rsaScheme) digest(hashed []byte) []byte {
+	if scheme.hash == crypto.Hash(0) {
+		return []byte(hex.EncodeToString(hashed))
+	}
+	return hashed
+}
+
+// Sign signs the digest of the string to sign
+func (scheme *rsaScheme) Sign(signer crypto.Signer, random io.Reader, stringToSign string) ([]byte, error) {
+	hashed := sha512.Sum512([]byte(stringToSign))
+	return scheme.signHashed(signer, random, hashed[:])
+}
+
+// signHashed signs the digest of the SHA-512 of the string to sign, for when the string to sign was
+// hashed as the body was read rather than built in memory
+func (scheme *rsaScheme) signHashed(signer crypto.Signer, random io.Reader, hashed []byte) ([]byte, error) {
+	// thanks to https://github.com/johnduhart for this
+	return signer.Sign(random, scheme.digest(hashed), scheme.hash)
+}
+
+// Verify checks the signature of the digest of the string to sign
+func (scheme *rsaScheme) Verify(publicKey crypto.PublicKey, stringToSign string, signature []byte) error {
+	rsaPublicKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("Public key is not an RSA key")
+	}
+	hashed := sha512.Sum512([]byte(stringToSign))
+	return rsa.VerifyPKCS1v15(rsaPublicKey, scheme.hash, scheme.digest(hashed[:]), signature)
+}
+
+var (
+	schemesMutex sync.RWMutex
+	// schemes holds every scheme by version
+	schemes = map[SignVersion]SignatureScheme{SignV1: schemeV1, SignV2: schemeV2}
+	// registeredSchemes holds the registered schemes, in the order they were registered
+	registeredSchemes []SignatureScheme
+)
+
+// RegisterSignatureScheme makes a scheme available for signing and verification.  The version and
+// headers must not clash with those of a scheme already registered or built in.
+func RegisterSignatureScheme(scheme SignatureScheme) error {
+	version := scheme.Version()
+	if version == "" || string(version) != strings.ToLower(string(version)) || strings.Contains(string(version), ",") {
+		return fmt.Errorf("Invalid signature scheme version %q", version)
+	}
+	if scheme.Token() == "" || scheme.AuthenticationHeader() == "" || scheme.TimeHeader() == "" {
+		return errors.New("Signature scheme needs a token and header names")
+	}
+	schemesMutex.Lock()
+	defer schemesMutex.Unlock()
+	for _, existing := range schemes {
+		if existing.Version() == version {
+			return fmt.Errorf("Signature scheme %q is already registered", version)
+		}
+		if strings.EqualFold(existing.AuthenticationHeader(), scheme.AuthenticationHeader()) {
+			return fmt.Errorf("Signature scheme %q already uses the %s header", existing.Version(),
+				scheme.AuthenticationHeader())
+		}
+	}
+	schemes[version] = scheme
+	registeredSchemes = append(registeredSchemes, scheme)
+	return nil
+}
+
+// LookupSignatureScheme returns the scheme of a version, built in or registered
+func LookupSignatureScheme(version SignVersion) (SignatureScheme, bool) {
+	schemesMutex.RLock()
+	defer schemesMutex.RUnlock()
+	scheme, known := schemes[version]
+	return scheme, known
+}
+
+// registeredSignatureSchemes returns the registered schemes, in the order they were registered
+func registeredSignatureSchemes() []SignatureScheme {
+	schemesMutex.RLock()
+	defer schemesMutex.RUnlock()
+	return append([]SignatureScheme(nil), registeredSchemes...)
+}
+
+// signatureSchemes returns the schemes for the App's versions other than the built in ones
+func (mauthApp *MAuthApp) signatureSchemes() ([]SignatureScheme, error) {
+	found := []SignatureScheme{}
+	for _, version := range mauthApp.SignVersions {
+		if version == SignV1 || version == SignV2 {
+			continue
+		}
+		scheme, known := LookupSignatureScheme(version)
+		if !known {
+			return nil, fmt.Errorf("Unknown MAuth signing version %q", version)
+		}
+		found = append(found, scheme)
+	}
+	return found, nil
+}
+
+// signsWithScheme reports whether the App signs with any version other than the built in ones
+func (mauthApp *MAuthApp) signsWithScheme() bool {
+	for _, version := range mauthApp.SignVersions {
+		if version != SignV1 && version != SignV2 {
+			return true
+		}
+	}
+	return false
+}
+
+// signWithScheme signs the string to sign with the App's signer and encodes the signature
+func signWithScheme(mauthApp *MAuthApp, scheme SignatureScheme, stringToSign string) (string, error) {
+	return mauthApp.encodeSignature(func(signer crypto.Signer, random io.Reader) ([]byte, error) {
+		return scheme.Sign(signer, random, stringToSign)
+	})
+}
+
+// signHashedWithScheme signs the SHA-512 of the string to sign with the App's signer and encodes the signature
+func signHashedWithScheme(mauthApp *MAuthApp, scheme *rsaScheme, hashed []byte) (string, error) {
+	return mauthApp.encodeSignature(func(signer crypto.Signer, random io.Reader) ([]byte, error) {
+		return scheme.signHashed(signer, random, hashed)
+	})
+}
+
+// encodeSignature signs with the App's signer and source of randomness and encodes the signature
+func (mauthApp *MAuthApp) encodeSignature(sign func(signer crypto.Signer, random io.Reader) ([]byte, error)) (string, error) {
+	signer, err := mauthApp.signer()
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := sign(signer, mauthApp.random())
+	if err != nil {
+		return "", err
+	}
+	// string needs to be base64 encoded; the standard encoding has no line breaks to remove
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// verifyWithScheme decodes the signature and checks it against the string to sign
+func verifyWithScheme(scheme SignatureScheme, publicKey crypto.PublicKey, stringToSign string, signature string) error {
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if scheme.Verify(publicKey, stringToSign, decoded) != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// MakeSchemeAuthenticationHeaders generates the headers of a scheme as a map for insertion into the
+// request headers
+func MakeSchemeAuthenticationHeaders(mauthApp *MAuthApp, scheme SignatureScheme, signed_string string,
+	seconds_since_epoch int64) map[string]string {
+	terminator := ";"
+	if builtIn, ok := scheme.(*rsaScheme); ok {
+		terminator = builtIn.terminator
+	}
+	authentication := fmt.Sprintf("%s %s:%s%s", scheme.Token(), mauthApp.AppId, signed_string, terminator)
+	headers := map[string]string{
+		scheme.AuthenticationHeader(): authentication,
+		scheme.TimeHeader():           strconv.FormatInt(seconds_since_epoch, 10),
+	}
+	return headers
+}
+
+// ParseSchemeAuthenticationHeader splits a "<token> <app_uuid>:<signature>;" header of a scheme
+func ParseSchemeAuthenticationHeader(scheme SignatureScheme, value string) (appId string, signature string, err error) {
+	if !strings.HasPrefix(value, scheme.Token()+" ") {
+		return "", "", ErrMalformedAuthentication
+	}
+	return splitAuthentication(strings.TrimSuffix(strings.TrimPrefix(value, scheme.Token()+" "), ";"))
+}
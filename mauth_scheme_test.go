@@ -0,0 +1,147 @@
+package go_mauth_client
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha512"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// pssScheme is an experimental RSA-PSS scheme, as a service might trial
+type pssScheme struct{}
+
+func (pssScheme) Version() SignVersion         { return "v3-pss" }
+func (pssScheme) Token() string                { return "MWSV3PSS" }
+func (pssScheme) AuthenticationHeader() string { return "MCC-PSS-Authentication" }
+func (pssScheme) TimeHeader() string           { return "MCC-PSS-Time" }
+
+func (pssScheme) Sign(signer crypto.Signer, random io.Reader, stringToSign string) ([]byte, error) {
+	hashed := sha512.Sum512([]byte(stringToSign))
+	return signer.Sign(random, hashed[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512})
+}
+
+func (pssScheme) Verify(publicKey crypto.PublicKey, stringToSign string, signature []byte) error {
+	hashed := sha512.Sum512([]byte(stringToSign))
+	return rsa.VerifyPSS(publicKey.(*rsa.PublicKey), crypto.SHA512, hashed[:], signature,
+		&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+}
+
+// ed25519Scheme is an experimental Ed25519 scheme, signing the string to sign itself
+type ed25519Scheme struct{}
+
+func (ed25519Scheme) Version() SignVersion         { return "v3-ed25519" }
+func (ed25519Scheme) Token() string                { return "MWSV3ED" }
+func (ed25519Scheme) AuthenticationHeader() string { return "MCC-Ed25519-Authentication" }
+func (ed25519Scheme) TimeHeader() string           { return "MCC-Ed25519-Time" }
+
+func (ed25519Scheme) Sign(signer crypto.Signer, random io.Reader, stringToSign string) ([]byte, error) {
+	return signer.Sign(random, []byte(stringToSign), crypto.Hash(0))
+}
+
+func (ed25519Scheme) Verify(publicKey crypto.PublicKey, stringToSign string, signature []byte) error {
+	key, ok := publicKey.(ed25519.PublicKey)
+	if !ok || !ed25519.Verify(key, []byte(stringToSign), signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// ed25519Provider supplies Ed25519 keys through SchemeKeyProvider
+type ed25519Provider struct {
+	key ed25519.PublicKey
+}
+
+func (provider ed25519Provider) PublicKey(appId string) (*rsa.PublicKey, error) {
+	return nil, errors.New("no RSA key")
+}
+
+func (provider ed25519Provider) SchemePublicKey(appId string, version SignVersion) (crypto.PublicKey, error) {
+	return provider.key, nil
+}
+
+var registerTestSchemes sync.Once
+
+// registerSchemes registers the test schemes, once for the whole test run
+func registerSchemes(t *testing.T) {
+	registerTestSchemes.Do(func() {
+		for _, scheme := range []SignatureScheme{pssScheme{}, ed25519Scheme{}} {
+			if err := RegisterSignatureScheme(scheme); err != nil {
+				t.Fatal("Unable to register scheme ", err)
+			}
+		}
+	})
+}
+
+// Test a registered scheme signs alongside V2 and is verified in preference to it
+func TestSignatureSchemeRSAPSS(t *testing.T) {
+	registerSchemes(t)
+	versions, err := ParseSignVersions("v2,V3-PSS")
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"),
+		SignVersions: versions})
+	req, _ := http.NewRequest("POST", "https://innovate.mdsol.com/api/v2/users.json?until=2100",
+		strings.NewReader(`{"user": "test"}`))
+	if err := mauthApp.SignRequest(req); err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+	if !strings.HasPrefix(req.Header.Get("MCC-PSS-Authentication"), "MWSV3PSS "+app_id+":") ||
+		req.Header.Get("MCC-PSS-Time") == "" || req.Header.Get("MCC-Authentication") == "" {
+		t.Fatal("Expected the V2 and PSS headers, got ", req.Header)
+	}
+	verifier := testVerifier(mauthApp)
+	if _, err := verifier.VerifyRequest(req); err != nil {
+		t.Error("Verification failed: ", err)
+	}
+	// the registered scheme is checked first, so a bad PSS signature fails despite a good V2 one
+	req.Header.Set("MCC-PSS-Authentication", "MWSV3PSS "+app_id+":"+strings.Repeat("A", 344)+";")
+	if _, err := verifier.VerifyRequest(req); err != ErrInvalidSignature {
+		t.Error("Expected ErrInvalidSignature, got ", err)
+	}
+}
+
+// Test a scheme using keys other than RSA keys, through a Signer and a SchemeKeyProvider
+func TestSignatureSchemeEd25519(t *testing.T) {
+	registerSchemes(t)
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	mauthApp := &MAuthApp{AppId: app_id, Signer: privateKey, SignVersions: []SignVersion{"v3-ed25519"}}
+	req, _ := http.NewRequest("GET", "https://innovate.mdsol.com/api/v2/users.json", nil)
+	if err := mauthApp.SignRequest(req); err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+	if req.Header.Get("MCC-Authentication") != "" || req.Header.Get("X-MWS-Authentication") != "" {
+		t.Error("Expected only the Ed25519 headers, got ", req.Header)
+	}
+	verifier := NewVerifier(ed25519Provider{key: publicKey})
+	if appId, err := verifier.VerifyRequest(req); err != nil || appId != app_id {
+		t.Errorf("Verification failed: %s, %v", appId, err)
+	}
+}
+
+// Test schemes which clash with those already known are refused
+func TestRegisterSignatureSchemeRejected(t *testing.T) {
+	registerSchemes(t)
+	tests := map[string]SignatureScheme{
+		"registered version": pssScheme{},
+		"built in header":    &rsaScheme{"v4", "MWSV4", "mcc-authentication", "MCC-Time", crypto.SHA512, ";"},
+		"upper case version": &rsaScheme{"V4", "MWSV4", "MCC-V4-Authentication", "MCC-V4-Time", crypto.SHA512, ";"},
+		"built in version":   &rsaScheme{SignV2, "MWSV4", "MCC-V4-Authentication", "MCC-V4-Time", crypto.SHA512, ";"},
+		"missing token":      &rsaScheme{"v4", "", "MCC-V4-Authentication", "MCC-V4-Time", crypto.SHA512, ";"},
+	}
+	for name, scheme := range tests {
+		if err := RegisterSignatureScheme(scheme); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := ParseSignVersions("v2,v4"); err == nil {
+		t.Error("Expected an error for an unregistered version")
+	}
+}
@@ -1,18 +1,10 @@
 package go_mauth_client
 
 import (
-	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha512"
-	"encoding/base64"
-	"encoding/hex"
-	"fmt"
 	"net/url"
+	"regexp"
 	"sort"
-	"strconv"
 	"strings"
-	"time"
 )
 
 /*
@@ -22,98 +14,155 @@ Wraps the functions around signing a request and generating the headers
 // MakeAuthenticationHeaders generates the formatted headers as a map for
 // insertion into the request headers.
 func MakeAuthenticationHeaders(mauthApp *MAuthApp, signed_string string, seconds_since_epoch int64) map[string]string {
-	headers := map[string]string{
-		"X-MWS-Authentication": fmt.Sprintf("MWS %s:%s", mauthApp.AppId, signed_string),
-		"X-MWS-Time":           strconv.FormatInt(seconds_since_epoch, 10),
-	}
-	return headers
+	return MakeSchemeAuthenticationHeaders(mauthApp, schemeV1, signed_string, seconds_since_epoch)
 }
 
 // MakeAuthenticationHeadersV2 generates the formatted headers as a map for
 // insertion into the request headers.
 func MakeAuthenticationHeadersV2(mauthApp *MAuthApp, signed_string string, seconds_since_epoch int64) map[string]string {
-	headers := map[string]string{
-		"MCC-Authentication": fmt.Sprintf("MWSV2 %s:%s;", mauthApp.AppId, signed_string),
-		"MCC-Time":           strconv.FormatInt(seconds_since_epoch, 10),
-	}
-	return headers
+	return MakeSchemeAuthenticationHeaders(mauthApp, schemeV2, signed_string, seconds_since_epoch)
 }
 
 // MakeSignatureString generates the string to be signed as part of the MWS header
 func MakeSignatureString(mauthApp *MAuthApp, method string, url string, body string, epoch int64) string {
-	if epoch == -1 {
-		epoch = time.Now().Unix()
-	}
-	// remove the query strings
-	return strings.Join([]string{method, strings.Split(url, "?")[0],
-		body, mauthApp.AppId, strconv.FormatInt(epoch, 10)}, "\n")
+	return NewStringToSign(mauthApp, method, url, body, epoch).String()
 }
 
 // MakeSignatureStringV2 generates the string to be signed as part of the MWS header
 func MakeSignatureStringV2(mauthApp *MAuthApp, method string, url string, body string, epoch int64) string {
-	if epoch == -1 {
-		epoch = time.Now().Unix()
-	}
+	return NewStringToSignV2(mauthApp, method, url, body, epoch).String()
+}
+
+// MakeSignatureStringV2FromHash generates the V2 string to sign from the hex encoded SHA-512 of the
+// body, for when the body hash is already known and the body need not be read
+func MakeSignatureStringV2FromHash(mauthApp *MAuthApp, method string, url string, hashedBody string, epoch int64) string {
+	return NewStringToSignV2FromHash(mauthApp, method, url, hashedBody, epoch).String()
+}
 
-	//hash body and query string
-	bodyHasher := sha512.New()
-	bodyHasher.Write([]byte(body))
-	hashedBody := hex.EncodeToString(bodyHasher.Sum(nil))
+var percentEncoding = regexp.MustCompile("%[0-9a-fA-F]{2}")
 
-	urlParts := strings.Split(url, "?")
+// normalizePath normalizes the path for V2 signing as the reference implementations do: "." and ".."
+// segments are resolved, repeated slashes collapsed and percent-encoding upper cased.
+// A trailing slash is preserved.
+func normalizePath(path string) string {
+	path = removeDotSegments(path)
+	for strings.Contains(path, "//") {
+		path = strings.Replace(path, "//", "/", -1)
+	}
+	return percentEncoding.ReplaceAllStringFunc(path, strings.ToUpper)
+}
 
-	encodedQueryParams := ""
-	if len(urlParts) > 1 {
-		encodedQueryParams = buildEncodedQueryParams(urlParts[1])
+// removeDotSegments resolves "." and ".." segments, following RFC 3986 section 5.2.4
+func removeDotSegments(input string) string {
+	output := ""
+	for input != "" {
+		switch {
+		case strings.HasPrefix(input, "../"):
+			input = input[3:]
+		case strings.HasPrefix(input, "./"):
+			input = input[2:]
+		case strings.HasPrefix(input, "/./"):
+			input = input[2:]
+		case input == "/.":
+			input = "/"
+		case strings.HasPrefix(input, "/../"):
+			input = input[3:]
+			output = output[:lastSlash(output)]
+		case input == "/..":
+			input = "/"
+			output = output[:lastSlash(output)]
+		case input == "." || input == "..":
+			input = ""
+		default:
+			// move the first segment, with its leading slash, to the output
+			next := strings.Index(input[1:], "/")
+			if next == -1 {
+				output += input
+				input = ""
+			} else {
+				output += input[:next+1]
+				input = input[next+1:]
+			}
+		}
 	}
+	return output
+}
 
-	// remove the query strings
-	return strings.Join([]string{method, urlParts[0],
-		hashedBody, mauthApp.AppId, strconv.FormatInt(epoch, 10), encodedQueryParams},
-		"\n")
+// lastSlash returns the index of the last "/" in path, or 0 if there is none
+func lastSlash(path string) int {
+	index := strings.LastIndex(path, "/")
+	if index == -1 {
+		return 0
+	}
+	return index
 }
 
+// buildEncodedQueryParams canonicalizes the query string for V2 signing: each key and value is
+// decoded, the pairs are sorted by key and then value, by code point, and then re-encoded per
+// RFC 3986, as the reference implementations do.
+// Repeated keys and empty values are kept; a key without "=" gets an empty value.
 func buildEncodedQueryParams(queryString string) string {
+	if queryString == "" {
+		return ""
+	}
+	pairs := [][2]string{}
+	for _, x := range strings.Split(queryString, "&") {
+		keyValue := strings.SplitN(x, "=", 2)
+		key, value := keyValue[0], ""
+		if len(keyValue) > 1 {
+			value = keyValue[1]
+		}
+		pairs = append(pairs, [2]string{unescapeQueryComponent(key), unescapeQueryComponent(value)})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
 	encodedQueryStrings := []string{}
-	var queryArray = strings.Split(queryString, "&")
-	sort.Strings(queryArray)
-	for _, x := range queryArray {
-		keyValue := strings.Split(x, "=")
-		var escapedKey = url.QueryEscape(keyValue[0])
-		var escapedValue = url.QueryEscape(keyValue[1])
-		encodedQueryStrings = append(encodedQueryStrings, escapedKey+"="+escapedValue)
+	for _, pair := range pairs {
+		encodedQueryStrings = append(encodedQueryStrings, escapeRFC3986(pair[0])+"="+escapeRFC3986(pair[1]))
 	}
 	return strings.Join(encodedQueryStrings, "&")
 }
 
-// SignString encrypts and encodes the string to sign
-func SignString(mauthApp *MAuthApp, stringToSign string) (s string, err error) {
-	// create a hasher
-	h := sha512.New()
-	h.Write([]byte(stringToSign))
-	hashed := hex.EncodeToString(h.Sum(nil))
-
-	// thanks to https://github.com/johnduhart for this
-	encrypted, err := rsa.SignPKCS1v15(rand.Reader, mauthApp.RsaPrivateKey, 0, []byte(hashed))
+// unescapeQueryComponent decodes a query key or value, keeping it as it is if it isn't validly encoded
+func unescapeQueryComponent(component string) string {
+	unescaped, err := url.QueryUnescape(component)
 	if err != nil {
-		return "", err
+		return component
+	}
+	return unescaped
+}
+
+// escapeRFC3986 percent-encodes everything but the RFC 3986 unreserved characters, using upper case hex
+func escapeRFC3986(s string) string {
+	const hex = "0123456789ABCDEF"
+	var escaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			escaped.WriteByte(c)
+		} else {
+			escaped.WriteByte('%')
+			escaped.WriteByte(hex[c>>4])
+			escaped.WriteByte(hex[c&15])
+		}
 	}
-	// string needs to be base64 encoded, with the newlines removed
-	signed := strings.Replace(base64.StdEncoding.EncodeToString(encrypted), "\n", "", -1)
-	return signed, err
+	return escaped.String()
+}
+
+// SignString encrypts and encodes the string to sign.
+// The V1 protocol signs the hex encoded digest itself, without a hash OID, so a MAuthApp.Signer
+// is asked to sign with crypto.Hash(0) as the options; it must then apply PKCS#1 v1.5 padding to
+// the bytes as given, which is what rsa.PrivateKey.Sign does.
+func SignString(mauthApp *MAuthApp, stringToSign string) (s string, err error) {
+	return signWithScheme(mauthApp, schemeV1, stringToSign)
 }
 
 // SignStringV2 encrypts and encodes the string to sign
 func SignStringV2(mauthApp *MAuthApp, stringToSign string) (s string, err error) {
-	// create a hasher
-	hashed := sha512.Sum512([]byte(stringToSign))
-
-	// thanks to https://github.com/johnduhart for this
-	encrypted, err := rsa.SignPKCS1v15(rand.Reader, mauthApp.RsaPrivateKey, crypto.SHA512, hashed[:])
-	if err != nil {
-		return "", err
-	}
-	// string needs to be base64 encoded, with the newlines removed
-	signed := strings.Replace(base64.StdEncoding.EncodeToString(encrypted), "\n", "", -1)
-	return signed, err
+	return signWithScheme(mauthApp, schemeV2, stringToSign)
 }
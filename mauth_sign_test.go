@@ -1,11 +1,13 @@
 package go_mauth_client
 
 import (
+	"crypto/rsa"
 	"fmt"
 	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -13,7 +15,7 @@ import (
 const app_id = "5ff4257e-9c16-11e0-b048-0026bbfffe5e"
 
 func TestMakeAuthenticationHeaders(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	now := time.Now()
 	secs := now.Unix()
 
@@ -30,7 +32,7 @@ func TestMakeAuthenticationHeaders(t *testing.T) {
 }
 
 func TestMakeAuthenticationHeadersV2(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	now := time.Now()
 	secs := now.Unix()
 
@@ -48,7 +50,7 @@ func TestMakeAuthenticationHeadersV2(t *testing.T) {
 
 func TestStringToSign(t *testing.T) {
 	epoch := time.Now().Unix()
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	expected := "GET" + "\n" + "/studies/123/users" + "\n" + "\n" + app_id + "\n" + strconv.FormatInt(epoch, 10)
 	actual := MakeSignatureString(mauthApp, "GET", "/studies/123/users", "", -1)
 	if actual != expected {
@@ -58,7 +60,7 @@ func TestStringToSign(t *testing.T) {
 
 func TestStringToSignV2(t *testing.T) {
 	epoch := time.Now().Unix()
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	expected := "GET" + "\n" + "/studies/123/users" + "\n" +
 		"cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e" + "\n" +
 		app_id + "\n" + strconv.FormatInt(epoch, 10) + "\n"
@@ -70,7 +72,7 @@ func TestStringToSignV2(t *testing.T) {
 
 func TestStringToSignNoQueryParams(t *testing.T) {
 	epoch := time.Now().Unix()
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	expected := "GET" + "\n" + "/studies/123/users" + "\n" + "\n" + app_id + "\n" + strconv.FormatInt(epoch, 10)
 	actual := MakeSignatureString(mauthApp, "GET", "/studies/123/users?until=2100", "", epoch)
 	if actual != expected {
@@ -81,7 +83,7 @@ func TestStringToSignNoQueryParams(t *testing.T) {
 
 func TestStringToSignV2QueryParams(t *testing.T) {
 	epoch := time.Now().Unix()
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	expected := "GET" + "\n" + "/studies/123/users" + "\n" +
 		"cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e" + "\n" +
 		app_id + "\n" + strconv.FormatInt(epoch, 10) + "\n" + "foo=bar1&foo=bar2&until=2100"
@@ -93,7 +95,7 @@ func TestStringToSignV2QueryParams(t *testing.T) {
 }
 
 func TestEpochDefinedIfMissing(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	actual := MakeSignatureString(mauthApp, "GET", "/studies/123/users", "", -1)
 	epochStr := strings.Split(actual, "\n")
 	epoch, _ := strconv.ParseInt(epochStr[4], 10, 64)
@@ -105,7 +107,7 @@ func TestEpochDefinedIfMissing(t *testing.T) {
 }
 
 func TestEpochDefinedIfMissingV2(t *testing.T) {
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	actual := MakeSignatureStringV2(mauthApp, "GET", "/studies/123/users", "", -1)
 	epochStr := strings.Split(actual, "\n")
 	epoch, _ := strconv.ParseInt(epochStr[4], 10, 64)
@@ -118,7 +120,7 @@ func TestEpochDefinedIfMissingV2(t *testing.T) {
 
 func TestSignString(t *testing.T) {
 	const message = "Hello world"
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	actual, _ := SignString(mauthApp, message)
 	expected := "ktlytWxKb6DnEaLlNRuDapLVf1DlkFCIY+/f+/VDZbH6tD6QFZ/8M3XklBEvaBqlYeACBptHZK52Yv9jbNO2gVdkQsb6Qo4467dDuHTpLmaeGTZetxZ8yuWwzHQGfqawgH9V6omnPrYbKJWaAzEdrlIxQqCWktibE6l1uW7pikZr+Y4NoDkIMavOgTdRJyOe1TDL+3GIIvIDTc5G+Mu7hNqxWRvnJTocAWFj/7ZA3GaBsHbZy9wwIzVmcloE5ahMFOlFIPI4e8DEa5sBsE7vklG25jRm8+E3GX7osslVY51RFh14KrJVIAu8gR9KzTlxRWRe8avoVf/q7CuiUyBOHA=="
 	if expected != actual {
@@ -128,10 +130,116 @@ func TestSignString(t *testing.T) {
 
 func TestSignStringV2(t *testing.T) {
 	const message = "Hello world"
-	mauthApp, _ := LoadMauth(MAuthOptions{app_id, filepath.Join("test", "private_key.pem"), false})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
 	actual, _ := SignStringV2(mauthApp, message)
 	expected := "KIFAy3SScS3gNX9m4cRQpyR+BoOKQAVQszWawwlN4ad/x4HUyHVXiNwIiSV+PecXsclexIzLlmanUqzSZgaJBR8u9gXlKi+XMYPFT7zceSl6q2hokiuZAg98tCsjvBExTKOKwfgbsuK033xPyiqDD64a8m2xAEw5eU98NfJ2E0m6WMD/ACzua8l66KgxdusGKmi6ZyPkc0Z+qpX3ig2Xu1eooqaUyAA+OqyJIziepgbMSXTiGoVyxhLEwfNy13BS774r6L0QEKcPwnIJ3Zu1D8ZslhSQv6V57YYPt//BfBl0B9gylfb8i+PQtHTXoXHM5bMrlkzTcqjWO2epeIENPg=="
 	if expected != actual {
 		t.Error("Encryption does not match: ", actual)
 	}
 }
+
+// Test a hand-built App's key is precomputed once it signs, even from several goroutines at once
+func TestSignStringPrecomputesKey(t *testing.T) {
+	loaded, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	privateKey := &rsa.PrivateKey{PublicKey: loaded.RsaPrivateKey.PublicKey, D: loaded.RsaPrivateKey.D,
+		Primes: loaded.RsaPrivateKey.Primes}
+	mauthApp := &MAuthApp{AppId: app_id, RsaPrivateKey: privateKey}
+	var wait sync.WaitGroup
+	signatures := make([]string, 4)
+	for i := range signatures {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			signatures[i], _ = SignStringV2(mauthApp, "Hello world")
+		}(i)
+	}
+	wait.Wait()
+	if privateKey.Precomputed.Dp == nil {
+		t.Error("Expected the key to be precomputed")
+	}
+	expected, _ := SignStringV2(loaded, "Hello world")
+	for _, signature := range signatures {
+		if signature != expected {
+			t.Error("Unexpected signature ", signature)
+		}
+	}
+}
+
+func TestBuildEncodedQueryParams(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"flag":                     "flag=",
+		"flag&a=1":                 "a=1&flag=",
+		"key=":                     "key=",
+		"a=b c":                    "a=b%20c",
+		"a=b+c":                    "a=b%20c",
+		"a=b%20c":                  "a=b%20c",
+		"a=b%2bc":                  "a=b%2Bc",
+		"a=~tilde":                 "a=~tilde",
+		"a=%7Etilde":               "a=~tilde",
+		"a=*star":                  "a=%2Astar",
+		"k=v=w":                    "k=v%3Dw",
+		"a=2&a=1&a=":               "a=&a=1&a=2",
+		"a-b=1&a=2":                "a=2&a-b=1",
+		"key=%E2%9C%93":            "key=%E2%9C%93",
+		"key=✓":                    "key=%E2%9C%93",
+		"bad=%zz":                  "bad=%25zz",
+		"until=2100&foo=bar2&foo=": "foo=&foo=bar2&until=2100",
+		// sorted by code point before encoding, as the reference implementations do
+		"a=z&a=%C3%A9": "a=z&a=%C3%A9",
+		"a=%C3%A9&a=z": "a=z&a=%C3%A9",
+		"b:=1&b0=2":    "b0=2&b%3A=1",
+		"b%3A=1&b0=2":  "b0=2&b%3A=1",
+	}
+	for query, expected := range tests {
+		actual := buildEncodedQueryParams(query)
+		if actual != expected {
+			t.Error("Query ", query, " expected ", expected, " got ", actual)
+		}
+	}
+}
+
+func TestStringToSignV2QueryParamsQuestionMark(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	actual := MakeSignatureStringV2(mauthApp, "GET", "/studies?q=what?", "", 1500000000)
+	if !strings.HasSuffix(actual, "\nq=what%3F") {
+		t.Error("Query not canonicalized: ", strings.Replace(actual, "\n", " ", -1))
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := map[string]string{
+		"":                      "",
+		"/":                     "/",
+		"/studies/123/users":    "/studies/123/users",
+		"/studies/123/users/":   "/studies/123/users/",
+		"/./studies":            "/studies",
+		"/studies/.":            "/studies/",
+		"/studies/123/..":       "/studies/",
+		"/studies/123/../users": "/studies/users",
+		"/../studies":           "/studies",
+		"/studies/./123/../../": "/",
+		"//studies///123//":     "/studies/123/",
+		"/studies//../users":    "/studies/users",
+		"/%cf%80/%Cf%8a":        "/%CF%80/%CF%8A",
+		"/studies/.hidden/..a":  "/studies/.hidden/..a",
+		"/studies/%2e%2e/users": "/studies/%2E%2E/users",
+	}
+	for path, expected := range tests {
+		actual := normalizePath(path)
+		if actual != expected {
+			t.Error("Path ", path, " expected ", expected, " got ", actual)
+		}
+	}
+}
+
+// Test equivalent paths produce the same string to sign, so signer and verifier agree
+func TestStringToSignV2NormalizedPath(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	expected := MakeSignatureStringV2(mauthApp, "GET", "/studies/123/users?until=2100", "", 1500000000)
+	actual := MakeSignatureStringV2(mauthApp, "GET", "//studies/./123/subjects/../users?until=2100", "", 1500000000)
+	if actual != expected {
+		t.Error("Signature String doesn't match: Expected ", strings.Replace(expected, "\n", " ", -1),
+			"Actual ", strings.Replace(actual, "\n", " ", -1))
+	}
+}
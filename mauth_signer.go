@@ -0,0 +1,171 @@
+package go_mauth_client
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+/*
+A reference crypto.Signer which keeps the private key in a separate signing agent, reached over a
+local socket.  The agent side can wrap any crypto.Signer (such as a KMS or HSM client), so the
+private key never needs to be loaded into the process making requests.
+
+The protocol is newline delimited JSON: each signerRequest on a connection is answered by
+a signerResponse.
+*/
+
+// signerRequest is sent to the signing agent; Operation is "public_key" or "sign"
+type signerRequest struct {
+	Operation string `json:"operation"`
+	Hash      string `json:"hash,omitempty"`
+	Digest    []byte `json:"digest,omitempty"`
+}
+
+// signerResponse is returned by the signing agent
+type signerResponse struct {
+	PublicKey []byte `json:"public_key,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// signerHashes are the hashes which can be named in a signerRequest; the empty name is
+// crypto.Hash(0), used by the V1 protocol
+var signerHashes = map[string]crypto.Hash{
+	"":        crypto.Hash(0),
+	"SHA-256": crypto.SHA256,
+	"SHA-384": crypto.SHA384,
+	"SHA-512": crypto.SHA512,
+}
+
+// hashName returns the name used for a hash in a signerRequest
+func hashName(hash crypto.Hash) (string, error) {
+	for name, known := range signerHashes {
+		if known == hash {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("Unsupported hash %v", hash)
+}
+
+// SocketSigner is a crypto.Signer which asks a signing agent listening on a socket to sign
+type SocketSigner struct {
+	network   string
+	address   string
+	publicKey *rsa.PublicKey
+}
+
+// NewSocketSigner connects to the signing agent at address (e.g. "unix", "/run/mauth/signer.sock")
+// and fetches its public key
+func NewSocketSigner(network string, address string) (*SocketSigner, error) {
+	signer := &SocketSigner{network: network, address: address}
+	response, err := signer.call(signerRequest{Operation: "public_key"})
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKIXPublicKey(response.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("Signing agent key is not an RSA key")
+	}
+	signer.publicKey = publicKey
+	return signer, nil
+}
+
+// Public returns the public key of the agent's private key
+func (signer *SocketSigner) Public() crypto.PublicKey {
+	return signer.publicKey
+}
+
+// Sign asks the agent to sign digest; opts.HashFunc() of 0 signs the digest without a hash OID.
+// The random argument is unused, the agent supplies its own randomness.
+func (signer *SocketSigner) Sign(random io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hash, err := hashName(opts.HashFunc())
+	if err != nil {
+		return nil, err
+	}
+	response, err := signer.call(signerRequest{Operation: "sign", Hash: hash, Digest: digest})
+	if err != nil {
+		return nil, err
+	}
+	return response.Signature, nil
+}
+
+// call sends a single request to the agent over a new connection
+func (signer *SocketSigner) call(request signerRequest) (*signerResponse, error) {
+	conn, err := net.Dial(signer.network, signer.address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return nil, err
+	}
+	var response signerResponse
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("Signing agent error: %s", response.Error)
+	}
+	return &response, nil
+}
+
+// ServeSigner runs a signing agent on listener, signing with signer, until the listener is closed
+func ServeSigner(listener net.Listener, signer crypto.Signer) error {
+	publicKey, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return err
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go serveSignerConn(conn, signer, publicKey)
+	}
+}
+
+// serveSignerConn answers the requests on a single agent connection
+func serveSignerConn(conn net.Conn, signer crypto.Signer, publicKey []byte) {
+	defer conn.Close()
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		var request signerRequest
+		if err := decoder.Decode(&request); err != nil {
+			return
+		}
+		var response signerResponse
+		switch request.Operation {
+		case "public_key":
+			response.PublicKey = publicKey
+		case "sign":
+			hash, known := signerHashes[request.Hash]
+			if !known {
+				response.Error = fmt.Sprintf("Unsupported hash %s", request.Hash)
+				break
+			}
+			signature, err := signer.Sign(rand.Reader, request.Digest, hash)
+			if err != nil {
+				response.Error = err.Error()
+				break
+			}
+			response.Signature = signature
+		default:
+			response.Error = fmt.Sprintf("Unknown operation %s", request.Operation)
+		}
+		if err := encoder.Encode(response); err != nil {
+			return
+		}
+	}
+}
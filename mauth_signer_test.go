@@ -0,0 +1,100 @@
+package go_mauth_client
+
+import (
+	"crypto"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// startSigningAgent runs a signing agent for the test private key on a unix socket
+func startSigningAgent(t *testing.T, mauthApp *MAuthApp) string {
+	dir, err := ioutil.TempDir("", "mauth-signer")
+	if err != nil {
+		t.Fatal("Unable to create socket directory: ", err)
+	}
+	address := filepath.Join(dir, "signer.sock")
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		t.Fatal("Unable to listen: ", err)
+	}
+	go func() {
+		_ = ServeSigner(listener, mauthApp.RsaPrivateKey)
+	}()
+	t.Cleanup(func() {
+		_ = listener.Close()
+		_ = os.RemoveAll(dir)
+	})
+	return address
+}
+
+func TestSocketSigner(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	address := startSigningAgent(t, mauthApp)
+	signer, err := NewSocketSigner("unix", address)
+	if err != nil {
+		t.Fatal("Unable to connect to signing agent: ", err)
+	}
+	if signer.publicKey.N.Cmp(mauthApp.RsaPrivateKey.N) != 0 {
+		t.Error("Public key doesn't match the agent's private key")
+	}
+	// the agent produces the same signatures as the key held in memory
+	remoteApp := &MAuthApp{AppId: app_id, Signer: signer}
+	for _, sign := range []func(*MAuthApp, string) (string, error){SignString, SignStringV2} {
+		expected, _ := sign(mauthApp, "Hello world")
+		actual, err := sign(remoteApp, "Hello world")
+		if err != nil {
+			t.Error("Error signing through agent: ", err)
+		}
+		if actual != expected {
+			t.Error("Signature does not match: ", actual)
+		}
+	}
+}
+
+func TestSocketSigner_SignRequest(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	signer, err := NewSocketSigner("unix", startSigningAgent(t, mauthApp))
+	if err != nil {
+		t.Fatal("Unable to connect to signing agent: ", err)
+	}
+	remoteApp := &MAuthApp{AppId: app_id, Signer: signer}
+	req, err := remoteApp.makeRequest("POST", "https://innovate.mdsol.com/api/v2/users.json",
+		`{"uuid":"1234-1234"}`, map[string][]string{})
+	if err != nil {
+		t.Error("Error making request: ", err)
+	}
+	req.Header.Del("MCC-Authentication")
+	// V1 only, as it takes the no hash OID path
+	if _, err := testVerifier(mauthApp).VerifyRequest(req); err != nil {
+		t.Error("Request did not verify: ", err)
+	}
+}
+
+func TestSocketSigner_UnsupportedHash(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	signer, _ := NewSocketSigner("unix", startSigningAgent(t, mauthApp))
+	if _, err := signer.Sign(nil, []byte("digest"), crypto.MD5); err == nil {
+		t.Error("Expected error for an unsupported hash")
+	}
+	response, _ := signer.call(signerRequest{Operation: "sign", Hash: "MD5"})
+	if response != nil {
+		t.Error("Expected agent to reject an unsupported hash")
+	}
+}
+
+func TestNewSocketSignerNoAgent(t *testing.T) {
+	_, err := NewSocketSigner("unix", filepath.Join("test", "missing.sock"))
+	if err == nil {
+		t.Error("Expected error connecting to a missing agent")
+	}
+}
+
+func TestSignStringNoKey(t *testing.T) {
+	mauthApp := &MAuthApp{AppId: app_id}
+	if _, err := SignStringV2(mauthApp, "Hello world"); err == nil {
+		t.Error("Expected error signing without a key")
+	}
+}
@@ -0,0 +1,201 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+Conformance checks against the shared MAuth protocol test suite, vendored in
+test/mauth-protocol-test-suite by test/update-protocol-test-suite.sh, and against this library's own
+regression cases in test/signing-cases, which use the same layout.  Each case in
+protocols/<version>/<case> has the request (.req), the expected string to sign (.sts), signature
+(.sig) and authentication headers (.authz), for the key and time in signing-config.json.
+*/
+
+var (
+	signingCasesDir   = filepath.Join("test", "signing-cases")
+	protocolSuiteDir  = filepath.Join("test", "mauth-protocol-test-suite")
+	signingCaseSuites = []string{protocolSuiteDir, signingCasesDir}
+)
+
+// signingConfig is the content of signing-config.json
+type signingConfig struct {
+	AppUUID        string `json:"app_uuid"`
+	RequestTime    int64  `json:"request_time"`
+	PrivateKeyFile string `json:"private_key_file"`
+}
+
+// caseRequest is the content of a .req file
+type caseRequest struct {
+	Verb         string `json:"verb"`
+	URL          string `json:"url"`
+	Body         string `json:"body"`
+	BodyFilepath string `json:"body_filepath"`
+}
+
+// signingCase holds the files of a single case
+type signingCase struct {
+	name          string
+	request       caseRequest
+	body          string
+	stringToSign  string
+	signature     string
+	authorization map[string]string
+}
+
+// loadSigningCasesApp loads the signing configuration shared by the cases of a suite
+func loadSigningCasesApp(t *testing.T, suite string) (*MAuthApp, signingConfig) {
+	content, err := ioutil.ReadFile(filepath.Join(suite, "signing-config.json"))
+	if err != nil {
+		t.Fatal("Unable to read signing config: ", err)
+	}
+	var config signingConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		t.Fatal("Unable to parse signing config: ", err)
+	}
+	mauthApp, err := LoadMauth(MAuthOptions{AppId: config.AppUUID,
+		KeySource: KeyFromFile(filepath.Join(suite, config.PrivateKeyFile))})
+	if err != nil {
+		t.Fatal("Unable to load signing case key: ", err)
+	}
+	return mauthApp, config
+}
+
+// loadSigningCases reads every case of a suite for a protocol version
+func loadSigningCases(t *testing.T, suite string, version string) []signingCase {
+	dirs, err := ioutil.ReadDir(filepath.Join(suite, "protocols", version))
+	if err != nil {
+		t.Fatal("Unable to list cases: ", err)
+	}
+	cases := []signingCase{}
+	for _, dir := range dirs {
+		name := dir.Name()
+		prefix := filepath.Join(suite, "protocols", version, name, name)
+		read := func(extension string) []byte {
+			content, err := ioutil.ReadFile(prefix + extension)
+			if err != nil {
+				t.Fatal("Unable to read case file: ", err)
+			}
+			return content
+		}
+		testCase := signingCase{name: name, stringToSign: string(read(".sts")),
+			signature: strings.TrimSpace(string(read(".sig")))}
+		if err := json.Unmarshal(read(".req"), &testCase.request); err != nil {
+			t.Fatal("Unable to parse request for ", name, ": ", err)
+		}
+		if err := json.Unmarshal(read(".authz"), &testCase.authorization); err != nil {
+			t.Fatal("Unable to parse headers for ", name, ": ", err)
+		}
+		testCase.body = testCase.request.Body
+		if testCase.request.BodyFilepath != "" {
+			body, err := ioutil.ReadFile(filepath.Join(suite, testCase.request.BodyFilepath))
+			if err != nil {
+				t.Fatal("Unable to read body for ", name, ": ", err)
+			}
+			testCase.body = string(body)
+		}
+		cases = append(cases, testCase)
+	}
+	return cases
+}
+
+// verifySigningCase checks the request, carrying the expected headers, with a verifier fixed at the request time
+func verifySigningCase(t *testing.T, mauthApp *MAuthApp, config signingConfig, testCase signingCase) {
+	req, err := http.NewRequest(testCase.request.Verb, "https://mauth.example.com"+testCase.request.URL,
+		bytes.NewReader([]byte(testCase.body)))
+	if err != nil {
+		t.Fatal("Unable to build request: ", err)
+	}
+	for header, value := range testCase.authorization {
+		req.Header.Set(header, value)
+	}
+	verifier := testVerifier(mauthApp)
+	verifier.clock = func() time.Time { return time.Unix(config.RequestTime, 0) }
+	if _, err := verifier.VerifyRequest(req); err != nil {
+		t.Error("Request did not verify: ", err)
+	}
+}
+
+func TestSigningCasesMWSV2(t *testing.T) {
+	for _, suite := range presentSigningCaseSuites() {
+		mauthApp, config := loadSigningCasesApp(t, suite)
+		for _, testCase := range loadSigningCases(t, suite, "MWSV2") {
+			t.Run(filepath.Base(suite)+"/"+testCase.name, func(t *testing.T) {
+				stringToSign := MakeSignatureStringV2(mauthApp, testCase.request.Verb, testCase.request.URL, testCase.body,
+					config.RequestTime)
+				if stringToSign != testCase.stringToSign {
+					t.Errorf("String to sign doesn't match:\nexpected %q\nactual   %q", testCase.stringToSign, stringToSign)
+				}
+				signature, err := SignStringV2(mauthApp, testCase.stringToSign)
+				if err != nil || signature != testCase.signature {
+					t.Error("Signature doesn't match: ", signature, err)
+				}
+				headers := MakeAuthenticationHeadersV2(mauthApp, testCase.signature, config.RequestTime)
+				for header, value := range testCase.authorization {
+					if headers[header] != value {
+						t.Error("Header ", header, " doesn't match: ", headers[header])
+					}
+				}
+				verifySigningCase(t, mauthApp, config, testCase)
+			})
+		}
+	}
+}
+
+func TestSigningCasesMWS(t *testing.T) {
+	for _, suite := range presentSigningCaseSuites() {
+		mauthApp, config := loadSigningCasesApp(t, suite)
+		for _, testCase := range loadSigningCases(t, suite, "MWS") {
+			t.Run(filepath.Base(suite)+"/"+testCase.name, func(t *testing.T) {
+				stringToSign := MakeSignatureString(mauthApp, testCase.request.Verb, testCase.request.URL, testCase.body,
+					config.RequestTime)
+				if stringToSign != testCase.stringToSign {
+					t.Errorf("String to sign doesn't match:\nexpected %q\nactual   %q", testCase.stringToSign, stringToSign)
+				}
+				signature, err := SignString(mauthApp, testCase.stringToSign)
+				if err != nil || signature != testCase.signature {
+					t.Error("Signature doesn't match: ", signature, err)
+				}
+				headers := MakeAuthenticationHeaders(mauthApp, testCase.signature, config.RequestTime)
+				for header, value := range testCase.authorization {
+					if headers[header] != value {
+						t.Error("Header ", header, " doesn't match: ", headers[header])
+					}
+				}
+				verifySigningCase(t, mauthApp, config, testCase)
+			})
+		}
+	}
+}
+
+// presentSigningCaseSuites lists the suites on disk; the upstream suite is only there once vendored
+func presentSigningCaseSuites() []string {
+	suites := []string{}
+	for _, suite := range signingCaseSuites {
+		if _, err := os.Stat(filepath.Join(suite, "signing-config.json")); err == nil {
+			suites = append(suites, suite)
+		}
+	}
+	return suites
+}
+
+// Test the cases are present, so a missing copy doesn't pass silently
+func TestSigningCasesPresent(t *testing.T) {
+	if _, err := os.Stat(filepath.Join(signingCasesDir, "protocols", "MWSV2")); err != nil {
+		t.Error("Signing cases are missing: ", err)
+	}
+	if _, err := os.Stat(filepath.Join(protocolSuiteDir, "protocols", "MWSV2")); err != nil {
+		t.Skip("The MAuth protocol test suite is not vendored, run test/update-protocol-test-suite.sh: ", err)
+	}
+	if _, err := os.Stat(filepath.Join(protocolSuiteDir, "LICENSE")); err != nil {
+		t.Error("The vendored MAuth protocol test suite is missing its license: ", err)
+	}
+}
@@ -0,0 +1,143 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Clock skew correction: the offset between the local clock and the servers' clocks is learnt from
+signed responses and applied to later signatures, so a drifting host clock doesn't push signatures
+outside the servers' time window
+*/
+
+// MaxClockSkew is the largest offset from server time a ClockSkew accepts; a response claiming a
+// larger one is taken to be wrong rather than the local clock
+const MaxClockSkew = time.Hour
+
+// maxSkewResponseBody is the largest response body read to verify a response's signature; the
+// skew isn't learnt from a larger response
+const maxSkewResponseBody = 1 << 20
+
+// ClockSkew tracks how far server time is ahead of the local clock, learnt from the MCC-Time of
+// responses whose MCC-Authentication verifies against the public keys of the servers.  Unsigned
+// times, such as the Date header, could be set by any host or proxy, so they are never used.  A
+// signed response is read into memory, up to maxSkewResponseBody, to verify it.  Responses served
+// from a cache are ignored and no offset beyond MaxClockSkew is accepted.  It is safe for
+// concurrent use.
+type ClockSkew struct {
+	// offset is the skew in nanoseconds
+	offset int64
+	// observed is the time of the last observation in Unix nanoseconds, zero before the first
+	observed int64
+	// keyProvider has the public keys of the servers whose responses are trusted
+	keyProvider PublicKeyProvider
+}
+
+// NewClockSkew creates a ClockSkew with no offset, for MAuthApp.SkewCorrection, which learns from
+// responses signed by the servers whose public keys keyProvider has
+func NewClockSkew(keyProvider PublicKeyProvider) *ClockSkew {
+	return &ClockSkew{keyProvider: keyProvider}
+}
+
+// Offset returns the measured skew: positive when server time is ahead of the local clock
+func (skew *ClockSkew) Offset() time.Duration {
+	return time.Duration(atomic.LoadInt64(&skew.offset))
+}
+
+// LastObserved returns when the skew was last measured, the zero time if it hasn't been
+func (skew *ClockSkew) LastObserved() time.Time {
+	observed := atomic.LoadInt64(&skew.observed)
+	if observed == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, observed)
+}
+
+// Observe measures the skew from a response received now, returning false if the response
+// carries no verified server time.  The response body is read and replaced.
+func (skew *ClockSkew) Observe(response *http.Response) bool {
+	return skew.observe(response, time.Now())
+}
+
+// observe measures the skew from a response received at now, by the local clock.
+// Server times are in whole seconds, so the offset is rounded to a whole second.
+func (skew *ClockSkew) observe(response *http.Response, now time.Time) bool {
+	serverTime, ok := skew.responseTime(response)
+	if !ok {
+		return false
+	}
+	offset := serverTime.Sub(now).Round(time.Second)
+	if offset > MaxClockSkew || offset < -MaxClockSkew {
+		return false
+	}
+	atomic.StoreInt64(&skew.offset, int64(offset))
+	atomic.StoreInt64(&skew.observed, now.UnixNano())
+	return true
+}
+
+// responseTime reads the server time from the MCC-Time of a response, once its MCC-Authentication
+// is verified.  A response with an Age was served from a cache, so its time is when it was first
+// made rather than now.
+func (skew *ClockSkew) responseTime(response *http.Response) (time.Time, bool) {
+	if skew.keyProvider == nil || response == nil || response.Header.Get("Age") != "" {
+		return time.Time{}, false
+	}
+	appId, signature, err := ParseAuthenticationHeaderV2(response.Header.Get("MCC-Authentication"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	epoch, err := strconv.ParseInt(response.Header.Get("MCC-Time"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	publicKey, err := skew.keyProvider.PublicKey(appId)
+	if err != nil {
+		return time.Time{}, false
+	}
+	body, ok := readResponseBody(response)
+	if !ok {
+		return time.Time{}, false
+	}
+	if VerifyStringV2(publicKey, MakeResponseSignatureStringV2(response.StatusCode, body, appId, epoch),
+		signature) != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(epoch, 0), true
+}
+
+// MakeResponseSignatureStringV2 generates the string a server signs its response with, under the
+// MWSV2 protocol
+func MakeResponseSignatureStringV2(statusCode int, body []byte, appId string, epoch int64) string {
+	return strconv.Itoa(statusCode) + "\n" + hashHex(body) + "\n" + appId + "\n" + strconv.FormatInt(epoch, 10)
+}
+
+// readResponseBody reads a response body of up to maxSkewResponseBody and replaces it, so the
+// caller still reads all of it; ok is false if the body is larger or can't be read
+func readResponseBody(response *http.Response) (body []byte, ok bool) {
+	if response.Body == nil || response.Body == http.NoBody {
+		return []byte{}, true
+	}
+	original := response.Body
+	body, err := ioutil.ReadAll(io.LimitReader(original, maxSkewResponseBody+1))
+	response.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), original), original}
+	if err != nil || len(body) > maxSkewResponseBody {
+		return nil, false
+	}
+	return body, true
+}
+
+// observeSkew learns the skew from a response if the App corrects for it
+func (mauthApp *MAuthApp) observeSkew(response *http.Response) {
+	if mauthApp.SkewCorrection != nil {
+		mauthApp.SkewCorrection.observe(response, mauthApp.localTime())
+	}
+}
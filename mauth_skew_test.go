@@ -0,0 +1,177 @@
+package go_mauth_client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serverKeys is the key provider for responses signed with the App's key
+func serverKeys(mauthApp *MAuthApp) PublicKeyProvider {
+	return StaticKeyProvider{app_id: &mauthApp.RsaPrivateKey.PublicKey}
+}
+
+// signResponse adds the headers of a response signed by the App at epoch, as a MAuth server does
+func signResponse(t *testing.T, mauthApp *MAuthApp, header http.Header, statusCode int, body []byte, epoch int64) {
+	signature, err := SignStringV2(mauthApp, MakeResponseSignatureStringV2(statusCode, body, mauthApp.AppId, epoch))
+	if err != nil {
+		t.Fatal("Unable to sign the response: ", err)
+	}
+	header.Set("MCC-Authentication", "MWSV2 "+mauthApp.AppId+":"+signature+";")
+	header.Set("MCC-Time", strconv.FormatInt(epoch, 10))
+}
+
+func TestClockSkew_observe(t *testing.T) {
+	now := time.Unix(1309891855, 0)
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	skew := NewClockSkew(serverKeys(mauthApp))
+	if !skew.LastObserved().IsZero() {
+		t.Error("Expected no observation yet")
+	}
+	signed := func(epoch int64, body string) *http.Response {
+		response := &http.Response{StatusCode: http.StatusOK, Header: http.Header{},
+			Body: ioutil.NopCloser(strings.NewReader(body))}
+		signResponse(t, mauthApp, response.Header, http.StatusOK, []byte(body), epoch)
+		return response
+	}
+	response := signed(now.Add(-90*time.Second).Unix(), "some data")
+	if !skew.observe(response, now) {
+		t.Error("Expected the skew to be observed")
+	}
+	if skew.Offset() != -90*time.Second {
+		t.Error("Expected an offset of -90s, got ", skew.Offset())
+	}
+	if !skew.LastObserved().Equal(now) {
+		t.Error("Unexpected observation time ", skew.LastObserved())
+	}
+	if body, _ := ioutil.ReadAll(response.Body); string(body) != "some data" {
+		t.Error("Expected the response body to be replaced, got ", string(body))
+	}
+	// unsigned and tampered times could come from any host or proxy
+	dated := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	dated.Header.Set("Date", now.Add(time.Minute).UTC().Format(http.TimeFormat))
+	dated.Header.Set("MCC-Time", strconv.FormatInt(now.Add(time.Minute).Unix(), 10))
+	if skew.observe(dated, now) {
+		t.Error("Expected no observation from an unsigned response")
+	}
+	tampered := signed(now.Unix(), "some data")
+	tampered.Header.Set("MCC-Time", strconv.FormatInt(now.Add(time.Minute).Unix(), 10))
+	if skew.observe(tampered, now) {
+		t.Error("Expected no observation from a response whose signature doesn't verify")
+	}
+	if NewClockSkew(nil).observe(signed(now.Unix(), ""), now) {
+		t.Error("Expected no observation without server keys")
+	}
+	// a cached response has the time it was first made
+	cached := signed(now.Unix(), "")
+	cached.Header.Set("Age", "600")
+	if skew.observe(cached, now.Add(-10*time.Minute)) {
+		t.Error("Expected no observation from a cached response")
+	}
+	if skew.observe(signed(now.Unix(), ""), now.Add(-2*time.Hour)) {
+		t.Error("Expected no observation beyond MaxClockSkew")
+	}
+	large := strings.Repeat("x", maxSkewResponseBody+1)
+	response = signed(now.Unix(), large)
+	if skew.observe(response, now.Add(time.Minute)) {
+		t.Error("Expected no observation from a response too large to verify")
+	}
+	if body, _ := ioutil.ReadAll(response.Body); string(body) != large {
+		t.Error("Expected the whole of a large response body to be kept")
+	}
+	if skew.Offset() != -90*time.Second {
+		t.Error("Expected the offset to be kept, got ", skew.Offset())
+	}
+}
+
+func TestLoadMauthCorrectClockSkew(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	if mauthApp.SkewCorrection != nil {
+		t.Error("Expected skew correction to be off by default")
+	}
+	if _, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"),
+		CorrectClockSkew: true}); err == nil {
+		t.Error("Expected skew correction without server keys to be refused")
+	}
+	mauthApp, _ = LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"),
+		CorrectClockSkew: true, ServerKeys: serverKeys(mauthApp)})
+	if mauthApp.SkewCorrection == nil {
+		t.Error("Expected skew correction to be on")
+	}
+}
+
+// Test a client with a slow clock learns the server time and signs later requests with it
+func TestMAuthClient_SkewCorrection(t *testing.T) {
+	serverTime := time.Now().Add(10 * time.Minute)
+	serverApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"),
+		CorrectClockSkew: true, ServerKeys: serverKeys(serverApp)})
+	verifier := testVerifier(mauthApp)
+	verifier.clock = FixedClock(serverTime)
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		if _, err := verifier.VerifyRequest(r); err != nil {
+			status = http.StatusUnauthorized
+		}
+		signResponse(t, serverApp, w.Header(), status, []byte{}, serverTime.Unix())
+		w.WriteHeader(status)
+	}))
+	defer httpServer.Close()
+	client, _ := mauthApp.CreateClient(httpServer.URL)
+	response, err := client.Get("/api/v2/users.json")
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Error("Expected the first request to be outside the time window, got ", response.Status)
+	}
+	if offset := mauthApp.SkewCorrection.Offset(); offset < 9*time.Minute || offset > 11*time.Minute {
+		t.Error("Expected an offset of about 10m, got ", offset)
+	}
+	response, _ = client.Get("/api/v2/users.json")
+	if response.StatusCode != http.StatusOK {
+		t.Error("Expected the corrected request to verify, got ", response.Status)
+	}
+}
+
+// Test the transport learns the skew from signed responses too, and not from an unsigned Date
+func TestMAuthTransport_SkewCorrection(t *testing.T) {
+	mauthApp := deterministicApp()
+	mauthApp.SkewCorrection = NewClockSkew(serverKeys(mauthApp))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Unix(1309891855, 0).Add(time.Minute).UTC().Format(http.TimeFormat))
+		if r.URL.Path == "/signed" {
+			body := []byte(r.Header.Get("MCC-Time"))
+			signResponse(t, mauthApp, w.Header(), http.StatusOK, body, 1309891855-120)
+			_, _ = w.Write(body)
+		}
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: mauthApp.NewTransport(nil)}
+	if _, err := client.Get(server.URL + "/unsigned"); err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+	if mauthApp.SkewCorrection.Offset() != 0 {
+		t.Error("Expected no offset from an unsigned response, got ", mauthApp.SkewCorrection.Offset())
+	}
+	response, err := client.Get(server.URL + "/signed")
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+	if body, _ := ioutil.ReadAll(response.Body); string(body) != "1309891855" {
+		t.Error("Unexpected response body ", string(body))
+	}
+	if mauthApp.SkewCorrection.Offset() != -2*time.Minute {
+		t.Error("Expected an offset of -2m, got ", mauthApp.SkewCorrection.Offset())
+	}
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	_ = mauthApp.SignRequest(req)
+	if req.Header.Get("MCC-Time") != "1309891735" {
+		t.Error("Expected the corrected signing time, got ", req.Header.Get("MCC-Time"))
+	}
+}
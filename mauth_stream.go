@@ -0,0 +1,183 @@
+package go_mauth_client
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+/*
+Signing of request bodies read from a stream, so large uploads don't need to be held in memory
+*/
+
+// bodyDigests holds what is needed from a request body to sign it
+type bodyDigests struct {
+	// stringToSign is the SHA-512 of the V1 string to sign, which embeds the body
+	stringToSign []byte
+	// body is the hex encoded SHA-512 of the body, for the V2 string to sign
+	body string
+	// size is the length of the body
+	size int64
+}
+
+// hashBody reads the body once, hashing it for each of the enabled protocol versions.
+// The V1 string to sign holds the body between the path and the App UUID, so it is hashed in
+// three parts, either side of the body coming from the StringToSign, rather than being built in memory.
+func (mauthApp *MAuthApp) hashBody(method string, target *url.URL, body io.Reader,
+	secondsSinceEpoch int64) (*bodyDigests, error) {
+	v1Hasher := getSHA512()
+	defer putSHA512(v1Hasher)
+	v2Hasher := getSHA512()
+	defer putSHA512(v2Hasher)
+	prefix, suffix := NewStringToSign(mauthApp, method, target.Path, "", secondsSinceEpoch).bodyAffixes()
+	hashers := make([]io.Writer, 0, 2)
+	if mauthApp.signsWith(SignV1) {
+		_, _ = io.WriteString(v1Hasher, prefix)
+		hashers = append(hashers, v1Hasher)
+	}
+	if mauthApp.signsWith(SignV2) || mauthApp.signsWithScheme() {
+		hashers = append(hashers, v2Hasher)
+	}
+	var hashing io.Writer = io.MultiWriter(hashers...)
+	if len(hashers) == 1 {
+		hashing = hashers[0]
+	}
+	size, err := copyPooled(hashing, body)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.WriteString(v1Hasher, suffix)
+	return &bodyDigests{stringToSign: v1Hasher.Sum(nil),
+		body: sumHex(v2Hasher),
+		size: size}, nil
+}
+
+// makeDigestHeaders signs the request details using the body digests and returns the MAuth
+// headers for the enabled protocol versions
+func (mauthApp *MAuthApp) makeDigestHeaders(method string, target *url.URL, digests *bodyDigests,
+	secondsSinceEpoch int64) (map[string]string, error) {
+	headers := make(map[string]string)
+	if mauthApp.signsWith(SignV1) {
+		signedString, err := signHashedWithScheme(mauthApp, schemeV1, digests.stringToSign)
+		if err != nil {
+			return nil, err
+		}
+		for header, value := range MakeSchemeAuthenticationHeaders(mauthApp, schemeV1, signedString, secondsSinceEpoch) {
+			headers[header] = value
+		}
+	}
+
+	schemes, err := mauthApp.signatureSchemes()
+	if err != nil {
+		return nil, err
+	}
+	if mauthApp.signsWith(SignV2) {
+		schemes = append([]SignatureScheme{schemeV2}, schemes...)
+	}
+	if len(schemes) == 0 {
+		return headers, nil
+	}
+	// V2 and the registered schemes sign the V2 string to sign
+	stringToSign := MakeSignatureStringV2FromHash(mauthApp, method, target.RequestURI(), digests.body, secondsSinceEpoch)
+	for _, scheme := range schemes {
+		signedString, err := signWithScheme(mauthApp, scheme, stringToSign)
+		if err != nil {
+			return nil, err
+		}
+		for header, value := range MakeSchemeAuthenticationHeaders(mauthApp, scheme, signedString, secondsSinceEpoch) {
+			headers[header] = value
+		}
+	}
+	return headers, nil
+}
+
+// makeStreamingRequest formulates a signed request whose body is read from the current position
+// of body.  The body is read once to hash it, then rewound and sent as it is read, so it is never
+// held in memory; it is not closed, that is left to the caller.
+func (mauthApp *MAuthApp) makeStreamingRequest(method string, rawurl string, body io.ReadSeeker,
+	contentType string, extraHeaders map[string][]string) (req *http.Request, err error) {
+
+	req, err = http.NewRequest(method, rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	// this needs to persist
+	secondsSinceEpoch := mauthApp.epoch()
+
+	digests, err := mauthApp.hashBody(method, req.URL, body, secondsSinceEpoch)
+	if err != nil {
+		return nil, err
+	}
+	madeHeaders, err := mauthApp.makeDigestHeaders(method, req.URL, digests, secondsSinceEpoch)
+	if err != nil {
+		return nil, err
+	}
+	// rewind for sending, and again for any redirect or retry
+	req.ContentLength = digests.size
+	req.GetBody = func() (io.ReadCloser, error) {
+		if _, err := body.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(body), nil
+	}
+	req.Body = http.NoBody
+	if digests.size > 0 {
+		req.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	decorateRequest(req, madeHeaders, contentType, extraHeaders)
+	return req, nil
+}
+
+// decorateRequest sets the MAuth headers, Content-Type, any extra headers and the User-Agent
+func decorateRequest(req *http.Request, madeHeaders map[string]string, contentType string,
+	extraHeaders map[string][]string) {
+	for header, value := range madeHeaders {
+		req.Header.Set(header, value)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	// Merge in any extra headers
+	for header, values := range extraHeaders {
+		for _, value := range values {
+			req.Header.Add(header, value)
+		}
+	}
+	// Add the User-Agent using the Client Version
+	req.Header.Set("User-Agent",
+		strings.Join([]string{"go-mauth-client", GetVersion()}, "/"))
+}
+
+// spoolBody copies a body which can't be rewound to a temporary file, hashing and sending
+// it from there; the file is removed by the returned cleanup function
+func spoolBody(body io.Reader) (file *os.File, cleanup func(), err error) {
+	file, err = ioutil.TempFile("", "mauth-body-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup = func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return file, cleanup, nil
+}
@@ -0,0 +1,168 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test the streamed digests match the strings to sign built in memory
+func TestHashBody(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	target, _ := url.Parse("https://innovate.mdsol.com/api/v2/users.json?b=2&a=1")
+	body := "\x00binary body\nwith a newline\xff"
+	digests, err := mauthApp.hashBody("PUT", target, bytes.NewReader([]byte(body)), 1234567890)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+	v1 := sha512.Sum512([]byte(MakeSignatureString(mauthApp, "PUT", target.Path, body, 1234567890)))
+	if !bytes.Equal(digests.stringToSign, v1[:]) {
+		t.Error("V1 digest does not match the string to sign")
+	}
+	v2 := sha512.Sum512([]byte(body))
+	if digests.body != hex.EncodeToString(v2[:]) {
+		t.Error("V2 body digest does not match the body")
+	}
+	if digests.size != int64(len(body)) {
+		t.Errorf("Expected size %d, got %d", len(body), digests.size)
+	}
+}
+
+// streamServer verifies each request and records the body it received
+func streamServer(t *testing.T, mauthApp *MAuthApp, received *[]byte) *httptest.Server {
+	verifier := testVerifier(mauthApp)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength < 0 {
+			t.Error("Expected a Content-Length")
+		}
+		if _, err := verifier.VerifyRequest(r); err != nil {
+			t.Error("Verification failed: ", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		*received, _ = ioutil.ReadAll(r.Body)
+	}))
+}
+
+// Test a file is streamed and signed with each protocol version
+func TestMAuthClient_PostReaderFile(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	file, _ := ioutil.TempFile("", "mauth-test-")
+	defer os.Remove(file.Name())
+	defer file.Close()
+	_, _ = file.Write(content)
+
+	for _, versions := range [][]SignVersion{{SignV1}, {SignV2}, {SignV1, SignV2}} {
+		mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"),
+			SignVersions: versions})
+		var received []byte
+		server := streamServer(t, mauthApp, &received)
+		client, _ := mauthApp.CreateClient(server.URL)
+		_, _ = file.Seek(0, io.SeekStart)
+		response, err := client.PostReader("/api/v2/uploads", file)
+		server.Close()
+		if err != nil {
+			t.Fatal("PostReader Failed: ", err)
+		}
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("%v: expected 200, got %d", versions, response.StatusCode)
+		}
+		if !bytes.Equal(received, content) {
+			t.Errorf("%v: the body received does not match the file", versions)
+		}
+	}
+}
+
+// Test a reader which can't be rewound is spooled and sent
+func TestMAuthClient_PutReaderStream(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	var received []byte
+	server := streamServer(t, mauthApp, &received)
+	defer server.Close()
+	client, _ := mauthApp.CreateClient(server.URL)
+	content := []byte("streamed body")
+	reader, writer := io.Pipe()
+	go func() {
+		_, _ = writer.Write(content)
+		_ = writer.Close()
+	}()
+	if _, err := client.PutReader("/api/v2/uploads", reader); err != nil {
+		t.Fatal("PutReader Failed: ", err)
+	}
+	if !bytes.Equal(received, content) {
+		t.Errorf("Expected %q, got %q", content, received)
+	}
+}
+
+// Test an *os.File which can't seek, such as a pipe or stdin, is spooled
+func TestMAuthClient_PostReaderOSPipe(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	var received []byte
+	server := streamServer(t, mauthApp, &received)
+	defer server.Close()
+	client, _ := mauthApp.CreateClient(server.URL)
+	content := []byte("piped body")
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal("Unable to create pipe: ", err)
+	}
+	defer reader.Close()
+	go func() {
+		_, _ = writer.Write(content)
+		_ = writer.Close()
+	}()
+	if _, err := client.PostReader("/api/v2/uploads", reader); err != nil {
+		t.Fatal("PostReader Failed: ", err)
+	}
+	if !bytes.Equal(received, content) {
+		t.Errorf("Expected %q, got %q", content, received)
+	}
+}
+
+// Test a binary body is signed and sent as bytes
+func TestMAuthClient_PostBytes(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	var received []byte
+	server := streamServer(t, mauthApp, &received)
+	defer server.Close()
+	client, _ := mauthApp.CreateClient(server.URL)
+	content := []byte{0x00, 0x01, 0xfe, 0xff}
+	if _, err := client.PostBytes("/api/v2/uploads", content); err != nil {
+		t.Fatal("PostBytes Failed: ", err)
+	}
+	if !bytes.Equal(received, content) {
+		t.Errorf("Expected %v, got %v", content, received)
+	}
+}
+
+// Test the version fallback sends a streamed body in full again on the retry
+func TestMAuthClient_PutReaderVersionFallback(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasMWSHeader(r) {
+			w.Header().Set("WWW-Authenticate", "MWS")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+	client, _ := mauthApp.CreateClient(server.URL)
+	client.EnableVersionFallback()
+	content := []byte("retried body")
+	if _, err := client.PutReader("/api/v2/uploads", bytes.NewReader(content)); err != nil {
+		t.Fatal("PutReader Failed: ", err)
+	}
+	if !bytes.Equal(received, content) {
+		t.Errorf("Expected %q, got %q", content, received)
+	}
+}
@@ -0,0 +1,126 @@
+package go_mauth_client
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+The string to sign as a model, shared by signing, verification and tools which need to build or
+inspect one
+*/
+
+// StringToSign holds the parts of the string signed by a MAuth protocol version
+type StringToSign struct {
+	// Version is the protocol version, which decides how the parts are rendered
+	Version SignVersion
+	Method  string
+	// Path is the request path, without the query string; V2 normalizes it
+	Path string
+	// Body is the raw body, which V1 signs
+	Body string
+	// BodyHash is the hex encoded SHA-512 of the body, which V2 signs
+	BodyHash string
+	AppId    string
+	// Time is the signing time in seconds since the epoch
+	Time int64
+	// Query is the canonical query string, which V2 signs
+	Query string
+}
+
+// NewStringToSign builds the V1 string to sign for a request; an epoch of -1 is the current time
+func NewStringToSign(mauthApp *MAuthApp, method string, url string, body string, epoch int64) *StringToSign {
+	if epoch == -1 {
+		epoch = mauthApp.epoch()
+	}
+	// remove the query strings
+	return &StringToSign{Version: SignV1,
+		Method: method,
+		Path:   strings.Split(url, "?")[0],
+		Body:   body,
+		AppId:  mauthApp.AppId,
+		Time:   epoch}
+}
+
+// NewStringToSignV2 builds the V2 string to sign for a request; an epoch of -1 is the current time
+func NewStringToSignV2(mauthApp *MAuthApp, method string, url string, body string, epoch int64) *StringToSign {
+	//hash body and query string
+	hashedBody := sha512.Sum512([]byte(body))
+	return NewStringToSignV2FromHash(mauthApp, method, url, hex.EncodeToString(hashedBody[:]), epoch)
+}
+
+// NewStringToSignV2FromHash builds the V2 string to sign from the hex encoded SHA-512 of the body
+func NewStringToSignV2FromHash(mauthApp *MAuthApp, method string, url string, hashedBody string,
+	epoch int64) *StringToSign {
+	if epoch == -1 {
+		epoch = mauthApp.epoch()
+	}
+
+	urlParts := strings.SplitN(url, "?", 2)
+
+	encodedQueryParams := ""
+	if len(urlParts) > 1 {
+		encodedQueryParams = buildEncodedQueryParams(urlParts[1])
+	}
+
+	return &StringToSign{Version: SignV2,
+		Method:   method,
+		Path:     normalizePath(urlParts[0]),
+		BodyHash: hashedBody,
+		AppId:    mauthApp.AppId,
+		Time:     epoch,
+		Query:    encodedQueryParams}
+}
+
+// String renders the string to sign in the layout of its protocol version
+func (stringToSign *StringToSign) String() string {
+	if stringToSign.Version == SignV1 {
+		prefix, suffix := stringToSign.bodyAffixes()
+		return prefix + stringToSign.Body + suffix
+	}
+	return strings.Join([]string{stringToSign.Method, stringToSign.Path, stringToSign.BodyHash,
+		stringToSign.AppId, strconv.FormatInt(stringToSign.Time, 10), stringToSign.Query}, "\n")
+}
+
+// bodyAffixes returns the V1 string to sign either side of the body, so a streamed body can be
+// hashed in place without building the string to sign in memory
+func (stringToSign *StringToSign) bodyAffixes() (prefix string, suffix string) {
+	return stringToSign.Method + "\n" + stringToSign.Path + "\n",
+		"\n" + stringToSign.AppId + "\n" + strconv.FormatInt(stringToSign.Time, 10)
+}
+
+// ParseStringToSign splits a rendered string to sign of the given version back into its parts.
+// A V1 body may hold newlines, so it is taken as everything between the path and the App UUID.
+func ParseStringToSign(version SignVersion, value string) (*StringToSign, error) {
+	lines := strings.Split(value, "\n")
+	stringToSign := &StringToSign{Version: version}
+	var epoch string
+	switch version {
+	case SignV1:
+		if len(lines) < 5 {
+			return nil, errors.New("A V1 string to sign has at least 5 lines")
+		}
+		last := len(lines) - 2
+		stringToSign.Method, stringToSign.Path = lines[0], lines[1]
+		stringToSign.Body = strings.Join(lines[2:last], "\n")
+		stringToSign.AppId, epoch = lines[last], lines[last+1]
+	case SignV2:
+		if len(lines) != 6 {
+			return nil, errors.New("A V2 string to sign has 6 lines")
+		}
+		stringToSign.Method, stringToSign.Path, stringToSign.BodyHash = lines[0], lines[1], lines[2]
+		stringToSign.AppId, epoch, stringToSign.Query = lines[3], lines[4], lines[5]
+	default:
+		return nil, fmt.Errorf("Unknown MAuth signing version %q", version)
+	}
+	var err error
+	stringToSign.Time, err = strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid time in string to sign: %v", err)
+	}
+	return stringToSign, nil
+}
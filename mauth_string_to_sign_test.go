@@ -0,0 +1,93 @@
+package go_mauth_client
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Test the V1 string to sign renders and parses back, including a body with newlines
+func TestNewStringToSign(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	stringToSign := NewStringToSign(mauthApp, "POST", "/studies/123/users?until=2100", "line one\nline two", 1500000000)
+	if stringToSign.Path != "/studies/123/users" {
+		t.Error("Expected the query string to be removed, got ", stringToSign.Path)
+	}
+	rendered := stringToSign.String()
+	if rendered != MakeSignatureString(mauthApp, "POST", "/studies/123/users?until=2100", "line one\nline two", 1500000000) {
+		t.Error("Expected MakeSignatureString to render the StringToSign")
+	}
+	parsed, err := ParseStringToSign(SignV1, rendered)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+	if !reflect.DeepEqual(parsed, stringToSign) {
+		t.Errorf("Expected %+v, got %+v", stringToSign, parsed)
+	}
+}
+
+// Test the V2 string to sign renders and parses back
+func TestNewStringToSignV2(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem")})
+	stringToSign := NewStringToSignV2(mauthApp, "GET", "//studies/./123?b=2&a=1", "", 1500000000)
+	expected := &StringToSign{Version: SignV2,
+		Method:   "GET",
+		Path:     "/studies/123",
+		BodyHash: "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e",
+		AppId:    app_id,
+		Time:     1500000000,
+		Query:    "a=1&b=2"}
+	if !reflect.DeepEqual(stringToSign, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, stringToSign)
+	}
+	rendered := stringToSign.String()
+	if rendered != MakeSignatureStringV2(mauthApp, "GET", "//studies/./123?b=2&a=1", "", 1500000000) {
+		t.Error("Expected MakeSignatureStringV2 to render the StringToSign")
+	}
+	parsed, err := ParseStringToSign(SignV2, rendered)
+	if err != nil {
+		t.Fatal("Unexpected error ", err)
+	}
+	if !reflect.DeepEqual(parsed, stringToSign) {
+		t.Errorf("Expected %+v, got %+v", stringToSign, parsed)
+	}
+}
+
+// Test strings to sign with the wrong layout are rejected
+func TestParseStringToSignInvalid(t *testing.T) {
+	tests := map[SignVersion]string{
+		SignV1: "GET\n/path\n" + app_id,
+		SignV2: "GET\n/path\nhash\n" + app_id + "\n1500000000",
+		"v3":   "GET\n/path\nhash\n" + app_id + "\n1500000000\n",
+	}
+	for version, value := range tests {
+		if _, err := ParseStringToSign(version, value); err == nil {
+			t.Errorf("%s: expected an error for %q", version, value)
+		}
+	}
+	if _, err := ParseStringToSign(SignV2, "GET\n/path\nhash\n"+app_id+"\nsoon\n"); err == nil {
+		t.Error("Expected an error for an invalid time")
+	}
+}
+
+// Test the authentication headers parse into the App UUID and signature
+func TestParseAuthenticationHeaders(t *testing.T) {
+	appId, signature, err := ParseAuthenticationHeader("MWS " + app_id + ":c2lnbmF0dXJl")
+	if err != nil || appId != app_id || signature != "c2lnbmF0dXJl" {
+		t.Errorf("Unexpected V1 parse: %s, %s, %v", appId, signature, err)
+	}
+	appId, signature, err = ParseAuthenticationHeaderV2("MWSV2 " + app_id + ":c2lnbmF0dXJl;")
+	if err != nil || appId != app_id || signature != "c2lnbmF0dXJl" {
+		t.Errorf("Unexpected V2 parse: %s, %s, %v", appId, signature, err)
+	}
+	for _, value := range []string{"", "MWS", "MWSV2 " + app_id + ":sig;", "MWS " + app_id, "MWS :sig"} {
+		if _, _, err := ParseAuthenticationHeader(value); err != ErrMalformedAuthentication {
+			t.Errorf("%q: expected ErrMalformedAuthentication, got %v", value, err)
+		}
+	}
+	for _, value := range []string{"MWS " + app_id + ":sig", "MWSV2 " + app_id + ":;"} {
+		if _, _, err := ParseAuthenticationHeaderV2(value); err != ErrMalformedAuthentication {
+			t.Errorf("%q: expected ErrMalformedAuthentication, got %v", value, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// MAuthTransport is a http.RoundTripper which signs every request before passing it on to Base,
+// so any http.Client (or SDK that accepts one) makes MAuth signed calls
+type MAuthTransport struct {
+	mauthApp *MAuthApp
+	// Base is the RoundTripper used to send the signed request, http.DefaultTransport if nil
+	Base http.RoundTripper
+}
+
+// NewTransport creates a MAuthTransport which signs requests with the App credentials
+func (mauthApp *MAuthApp) NewTransport(base http.RoundTripper) *MAuthTransport {
+	return &MAuthTransport{mauthApp: mauthApp, Base: base}
+}
+
+// RoundTrip signs a copy of the request and sends it.  The original request is not modified;
+// the copy has a GetBody, so it can be sent again on redirects and retries.
+func (transport *MAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	signed := req.Clone(req.Context())
+	body := []byte{}
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = readBody(req.Body, req.ContentLength)
+		// the RoundTripper is responsible for closing the body, even on errors
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		signed.Body = ioutil.NopCloser(bytes.NewReader(body))
+		signed.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	madeHeaders, err := transport.mauthApp.makeBodyHeaders(signed.Method, signed.URL, bytes.NewReader(body),
+		transport.mauthApp.epoch())
+	if err != nil {
+		return nil, err
+	}
+	for header, value := range madeHeaders {
+		signed.Header.Set(header, value)
+	}
+	base := transport.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	response, err := base.RoundTrip(signed)
+	if err == nil {
+		transport.mauthApp.observeSkew(response)
+	}
+	return response, err
+}
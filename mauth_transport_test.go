@@ -0,0 +1,131 @@
+package go_mauth_client
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc adapts a function to a http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Test the requests sent through the transport are signed
+func TestMAuthTransport_RoundTrip(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	verifier := testVerifier(mauthApp)
+	var verifyErr error
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, verifyErr = verifier.VerifyRequest(r)
+		content, _ := ioutil.ReadAll(r.Body)
+		received = string(content)
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: mauthApp.NewTransport(nil)}
+	response, err := client.Post(server.URL+"/api/v2/users.json?until=2100", "application/json",
+		strings.NewReader(`{"uuid":"1234-1234"}`))
+	if err != nil {
+		t.Fatal("Post Failed: ", err)
+	}
+	_ = response.Body.Close()
+	if verifyErr != nil {
+		t.Error("Request did not verify: ", verifyErr)
+	}
+	if received != `{"uuid":"1234-1234"}` {
+		t.Error("Unexpected request body: ", received)
+	}
+}
+
+// Test the caller's request is left untouched
+func TestMAuthTransport_RoundTripClones(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: true})
+	var sent *http.Request
+	transport := mauthApp.NewTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	req, _ := http.NewRequest("PUT", "https://innovate.mdsol.com/api/v2/users.json",
+		strings.NewReader("some data"))
+	_, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Error("RoundTrip Failed: ", err)
+	}
+	if hasMCCHeader(req) {
+		t.Error("Original request was modified")
+	}
+	if !hasMCCHeader(sent) {
+		t.Error("Expected MCC header not present")
+	}
+	if hasMWSHeader(sent) {
+		t.Error("Unexpected MWS header present with V1 disabled")
+	}
+	// the body can be replayed for retries
+	for i := 0; i < 2; i++ {
+		body, _ := sent.GetBody()
+		content, _ := ioutil.ReadAll(body)
+		if string(content) != "some data" {
+			t.Error("Unexpected body from GetBody: ", string(content))
+		}
+	}
+}
+
+// Test a redirected POST is signed again for the new location
+func TestMAuthTransport_RoundTripRedirect(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	verifier := testVerifier(mauthApp)
+	var verifyErr error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusPermanentRedirect)
+			return
+		}
+		_, verifyErr = verifier.VerifyRequest(r)
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: mauthApp.NewTransport(nil)}
+	response, err := client.Post(server.URL+"/old", "text/plain", strings.NewReader("some data"))
+	if err != nil {
+		t.Fatal("Post Failed: ", err)
+	}
+	_ = response.Body.Close()
+	if verifyErr != nil {
+		t.Error("Redirected request did not verify: ", verifyErr)
+	}
+}
+
+func TestMAuthTransport_RoundTripBaseError(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	transport := mauthApp.NewTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}))
+	req, _ := http.NewRequest("GET", "https://innovate.mdsol.com/api/v2/users.json", nil)
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Error("Expected error from the base transport")
+	}
+}
+
+// Example of making an ordinary http.Client MAuth aware
+func ExampleMAuthApp_NewTransport() {
+	var appUUID = "7D0B2A90-0825-4AD8-9C1F-E9851795D428"
+	mAuthApp, err := LoadMauth(MAuthOptions{AppId: appUUID, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	if err != nil {
+		log.Fatal("Unable to create mAuthApp: ", err)
+	}
+	client := &http.Client{Transport: mAuthApp.NewTransport(http.DefaultTransport)}
+	response, err := client.Get("https://innovate.imedidata.com/api/v2/users.json")
+	if err != nil {
+		log.Fatal("Request failed: ", err)
+	}
+	println("Got a status code of", response.StatusCode)
+}
@@ -0,0 +1,263 @@
+package go_mauth_client
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Wraps the functions around verifying a signed request
+*/
+
+// DefaultTimeWindow is the period either side of now that a MAuth signature time is accepted for
+const DefaultTimeWindow = 300 * time.Second
+
+var (
+	// ErrMissingAuthentication is returned when a request carries no MAuth headers
+	ErrMissingAuthentication = errors.New("No MAuth authentication headers present")
+	// ErrMalformedAuthentication is returned when a MAuth header can't be split into its parts
+	ErrMalformedAuthentication = errors.New("Malformed MAuth authentication header")
+	// ErrTimeOutsideWindow is returned when the signature time is too far from now
+	ErrTimeOutsideWindow = errors.New("MAuth signature time is outside the allowed window")
+	// ErrInvalidSignature is returned when the signature doesn't match the request
+	ErrInvalidSignature = errors.New("MAuth signature does not match the request")
+)
+
+// PublicKeyProvider looks up the public key registered for an App UUID
+type PublicKeyProvider interface {
+	PublicKey(appId string) (*rsa.PublicKey, error)
+}
+
+// StaticKeyProvider is a PublicKeyProvider backed by a fixed map of App UUID to public key
+type StaticKeyProvider map[string]*rsa.PublicKey
+
+// PublicKey returns the key registered for appId
+func (provider StaticKeyProvider) PublicKey(appId string) (*rsa.PublicKey, error) {
+	key, exists := provider[strings.ToLower(appId)]
+	if !exists {
+		key, exists = provider[appId]
+	}
+	if !exists {
+		return nil, fmt.Errorf("No public key known for App UUID %s", appId)
+	}
+	return key, nil
+}
+
+// LoadPublicKey parses a PEM encoded RSA public key, in either PKIX ("PUBLIC KEY") or
+// PKCS#1 ("RSA PUBLIC KEY") form
+func LoadPublicKey(content string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(content))
+	if block == nil {
+		return nil, errors.New("Unable to extract PEM content")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("Public key is not an RSA key")
+	}
+	return publicKey, nil
+}
+
+// MAuthVerifier holds the context needed to authenticate signed requests
+type MAuthVerifier struct {
+	KeyProvider PublicKeyProvider
+	// TimeWindow is the allowed difference between the signature time and now
+	TimeWindow time.Duration
+	// DisableV1 rejects requests which are only signed with the MWS protocol
+	DisableV1 bool
+	// DecompressGzip replaces a gzip encoded request body, once the compressed body is verified,
+	// with the decompressed body so the handler needn't decompress it
+	DecompressGzip bool
+	// MessageTimeWindow is the maximum age of a signed message, DefaultMessageTimeWindow if zero;
+	// a negative window accepts messages of any age
+	MessageTimeWindow time.Duration
+	// clock returns the current time, time.Now if nil
+	clock func() time.Time
+}
+
+// NewVerifier creates a MAuthVerifier using the default time window
+func NewVerifier(keyProvider PublicKeyProvider) *MAuthVerifier {
+	return &MAuthVerifier{KeyProvider: keyProvider, TimeWindow: DefaultTimeWindow}
+}
+
+// VerifyRequest authenticates a http.Request, returning the App UUID that signed it.
+// The request body is read and replaced, so the request can still be handled afterwards.
+// A gzip encoded body is verified as it was sent, compressed.
+func (verifier *MAuthVerifier) VerifyRequest(req *http.Request) (appId string, err error) {
+	body, err := readAndRestoreBody(req)
+	if err != nil {
+		return "", err
+	}
+	appId, err = verifier.verify(req.Method, req.URL, body, req.Header)
+	if err != nil {
+		return "", err
+	}
+	if verifier.DecompressGzip && isGzipEncoded(req.Header) {
+		if err := decompressRequest(req, body); err != nil {
+			return "", err
+		}
+	}
+	return appId, nil
+}
+
+// verify checks the headers of a registered scheme if present, then the V2 (MCC) headers, falling
+// back to the V1 (MWS) headers
+func (verifier *MAuthVerifier) verify(method string, target *url.URL, body []byte,
+	header http.Header) (appId string, err error) {
+	for _, scheme := range registeredSignatureSchemes() {
+		if authentication := header.Get(scheme.AuthenticationHeader()); authentication != "" {
+			return verifier.verifyScheme(scheme, authentication, method, target, body, header)
+		}
+	}
+	if authentication := header.Get("MCC-Authentication"); authentication != "" {
+		appId, signature, err := ParseAuthenticationHeaderV2(authentication)
+		if err != nil {
+			return "", err
+		}
+		epoch, err := verifier.checkTime(header.Get("MCC-Time"))
+		if err != nil {
+			return "", err
+		}
+		publicKey, err := verifier.KeyProvider.PublicKey(appId)
+		if err != nil {
+			return "", err
+		}
+		stringToSign := MakeSignatureStringV2FromHash(&MAuthApp{AppId: appId}, method, target.RequestURI(),
+			hashHex(body), epoch)
+		if err := VerifyStringV2(publicKey, stringToSign, signature); err != nil {
+			return "", err
+		}
+		return appId, nil
+	}
+	if authentication := header.Get("X-MWS-Authentication"); authentication != "" && !verifier.DisableV1 {
+		appId, signature, err := ParseAuthenticationHeader(authentication)
+		if err != nil {
+			return "", err
+		}
+		epoch, err := verifier.checkTime(header.Get("X-MWS-Time"))
+		if err != nil {
+			return "", err
+		}
+		publicKey, err := verifier.KeyProvider.PublicKey(appId)
+		if err != nil {
+			return "", err
+		}
+		stringToSign := MakeSignatureString(&MAuthApp{AppId: appId}, method, target.Path, string(body), epoch)
+		if err := VerifyString(publicKey, stringToSign, signature); err != nil {
+			return "", err
+		}
+		return appId, nil
+	}
+	return "", ErrMissingAuthentication
+}
+
+// verifyScheme checks the headers of a registered scheme, which signs the V2 string to sign
+func (verifier *MAuthVerifier) verifyScheme(scheme SignatureScheme, authentication string, method string,
+	target *url.URL, body []byte, header http.Header) (appId string, err error) {
+	appId, signature, err := ParseSchemeAuthenticationHeader(scheme, authentication)
+	if err != nil {
+		return "", err
+	}
+	epoch, err := verifier.checkTime(header.Get(scheme.TimeHeader()))
+	if err != nil {
+		return "", err
+	}
+	var publicKey crypto.PublicKey
+	if provider, ok := verifier.KeyProvider.(SchemeKeyProvider); ok {
+		publicKey, err = provider.SchemePublicKey(appId, scheme.Version())
+	} else {
+		publicKey, err = verifier.KeyProvider.PublicKey(appId)
+	}
+	if err != nil {
+		return "", err
+	}
+	stringToSign := MakeSignatureStringV2FromHash(&MAuthApp{AppId: appId}, method, target.RequestURI(),
+		hashHex(body), epoch)
+	if err := verifyWithScheme(scheme, publicKey, stringToSign, signature); err != nil {
+		return "", err
+	}
+	return appId, nil
+}
+
+// checkTime parses the epoch seconds and confirms they are within the time window
+func (verifier *MAuthVerifier) checkTime(value string) (int64, error) {
+	window := verifier.TimeWindow
+	if window == 0 {
+		window = DefaultTimeWindow
+	}
+	return checkTimeWindow(value, window, verifier.now())
+}
+
+// now returns the current time according to the verifier's clock
+func (verifier *MAuthVerifier) now() time.Time {
+	if verifier.clock != nil {
+		return verifier.clock()
+	}
+	return time.Now()
+}
+
+// checkTimeWindow parses the epoch seconds and confirms they are within window of now;
+// a negative window accepts any time
+func checkTimeWindow(value string, window time.Duration, now time.Time) (int64, error) {
+	epoch, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, ErrMalformedAuthentication
+	}
+	if window < 0 {
+		return epoch, nil
+	}
+	skew := now.Sub(time.Unix(epoch, 0))
+	if skew > window || skew < -window {
+		return 0, ErrTimeOutsideWindow
+	}
+	return epoch, nil
+}
+
+// ParseAuthenticationHeader splits a "MWS <app_uuid>:<signature>" header
+func ParseAuthenticationHeader(value string) (appId string, signature string, err error) {
+	if !strings.HasPrefix(value, "MWS ") {
+		return "", "", ErrMalformedAuthentication
+	}
+	return splitAuthentication(strings.TrimPrefix(value, "MWS "))
+}
+
+// ParseAuthenticationHeaderV2 splits a "MWSV2 <app_uuid>:<signature>;" header
+func ParseAuthenticationHeaderV2(value string) (appId string, signature string, err error) {
+	if !strings.HasPrefix(value, "MWSV2 ") {
+		return "", "", ErrMalformedAuthentication
+	}
+	return splitAuthentication(strings.TrimSuffix(strings.TrimPrefix(value, "MWSV2 "), ";"))
+}
+
+func splitAuthentication(value string) (appId string, signature string, err error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", ErrMalformedAuthentication
+	}
+	return parts[0], parts[1], nil
+}
+
+// VerifyString checks a V1 signature, created by SignString, against the string to sign
+func VerifyString(publicKey *rsa.PublicKey, stringToSign string, signature string) error {
+	return verifyWithScheme(schemeV1, publicKey, stringToSign, signature)
+}
+
+// VerifyStringV2 checks a V2 signature, created by SignStringV2, against the string to sign
+func VerifyStringV2(publicKey *rsa.PublicKey, stringToSign string, signature string) error {
+	return verifyWithScheme(schemeV2, publicKey, stringToSign, signature)
+}
@@ -0,0 +1,168 @@
+package go_mauth_client
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testVerifier creates a verifier which knows the public key for the test App
+func testVerifier(mauthApp *MAuthApp) *MAuthVerifier {
+	return NewVerifier(StaticKeyProvider{app_id: &mauthApp.RsaPrivateKey.PublicKey})
+}
+
+func TestLoadPublicKey(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	pkix, _ := x509.MarshalPKIXPublicKey(&mauthApp.RsaPrivateKey.PublicKey)
+	content := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}))
+	publicKey, err := LoadPublicKey(content)
+	if err != nil {
+		t.Error("Error loading PKIX public key: ", err)
+	}
+	if publicKey.N.Cmp(mauthApp.RsaPrivateKey.N) != 0 {
+		t.Error("Public key doesn't match the private key")
+	}
+	pkcs1 := x509.MarshalPKCS1PublicKey(&mauthApp.RsaPrivateKey.PublicKey)
+	content = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: pkcs1}))
+	publicKey, err = LoadPublicKey(content)
+	if err != nil {
+		t.Error("Error loading PKCS#1 public key: ", err)
+	}
+	if publicKey.N.Cmp(mauthApp.RsaPrivateKey.N) != 0 {
+		t.Error("Public key doesn't match the private key")
+	}
+	_, err = LoadPublicKey("Platypus")
+	if err == nil {
+		t.Error("Expected error loading a non-PEM public key")
+	}
+}
+
+func TestStaticKeyProvider(t *testing.T) {
+	provider := StaticKeyProvider{app_id: &rsa.PublicKey{}}
+	if _, err := provider.PublicKey(strings.ToUpper(app_id)); err != nil {
+		t.Error("Expected App UUID lookup to ignore case")
+	}
+	if _, err := provider.PublicKey("7D0B2A90-0825-4AD8-9C1F-E9851795D428"); err == nil {
+		t.Error("Expected error for an unknown App UUID")
+	}
+}
+
+func TestVerifyString(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	signed, _ := SignString(mauthApp, "Hello world")
+	if err := VerifyString(&mauthApp.RsaPrivateKey.PublicKey, "Hello world", signed); err != nil {
+		t.Error("Signature did not verify: ", err)
+	}
+	if err := VerifyString(&mauthApp.RsaPrivateKey.PublicKey, "Hello world!", signed); err != ErrInvalidSignature {
+		t.Error("Expected ErrInvalidSignature for a different string, got ", err)
+	}
+}
+
+func TestVerifyStringV2(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	signed, _ := SignStringV2(mauthApp, "Hello world")
+	if err := VerifyStringV2(&mauthApp.RsaPrivateKey.PublicKey, "Hello world", signed); err != nil {
+		t.Error("Signature did not verify: ", err)
+	}
+	if err := VerifyStringV2(&mauthApp.RsaPrivateKey.PublicKey, "Hello world", "not base64!"); err != ErrInvalidSignature {
+		t.Error("Expected ErrInvalidSignature for a bad signature, got ", err)
+	}
+}
+
+func TestMAuthVerifier_VerifyRequest(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	req, _ := mauthApp.makeRequest("POST", "https://innovate.mdsol.com/api/v2/users.json?until=2100",
+		`{"uuid":"1234-1234"}`, map[string][]string{})
+	appId, err := testVerifier(mauthApp).VerifyRequest(req)
+	if err != nil {
+		t.Error("Request did not verify: ", err)
+	}
+	if appId != app_id {
+		t.Error("Unexpected App UUID ", appId)
+	}
+	// the body is still readable
+	body, _ := readAndRestoreBody(req)
+	if string(body) != `{"uuid":"1234-1234"}` {
+		t.Error("Request body was not restored")
+	}
+}
+
+func TestMAuthVerifier_VerifyRequestV1Only(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	req, _ := mauthApp.makeRequest("GET", "https://innovate.mdsol.com/api/v2/users.json", "",
+		map[string][]string{})
+	req.Header.Del("MCC-Authentication")
+	verifier := testVerifier(mauthApp)
+	if _, err := verifier.VerifyRequest(req); err != nil {
+		t.Error("V1 request did not verify: ", err)
+	}
+	verifier.DisableV1 = true
+	if _, err := verifier.VerifyRequest(req); err != ErrMissingAuthentication {
+		t.Error("Expected ErrMissingAuthentication with V1 disabled, got ", err)
+	}
+}
+
+func TestMAuthVerifier_VerifyRequestTampered(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	req, _ := mauthApp.makeRequest("GET", "https://innovate.mdsol.com/api/v2/users.json", "",
+		map[string][]string{})
+	req.URL.Path = "/api/v2/studies.json"
+	if _, err := testVerifier(mauthApp).VerifyRequest(req); err != ErrInvalidSignature {
+		t.Error("Expected ErrInvalidSignature for a changed path, got ", err)
+	}
+}
+
+func TestMAuthVerifier_VerifyRequestExpired(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	req, _ := mauthApp.makeRequest("GET", "https://innovate.mdsol.com/api/v2/users.json", "",
+		map[string][]string{})
+	req.Header.Set("MCC-Time", strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+	if _, err := testVerifier(mauthApp).VerifyRequest(req); err != ErrTimeOutsideWindow {
+		t.Error("Expected ErrTimeOutsideWindow, got ", err)
+	}
+}
+
+func TestMAuthVerifier_VerifyRequestMissing(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	req, _ := http.NewRequest("GET", "https://innovate.mdsol.com/api/v2/users.json", nil)
+	if _, err := testVerifier(mauthApp).VerifyRequest(req); err != ErrMissingAuthentication {
+		t.Error("Expected ErrMissingAuthentication, got ", err)
+	}
+	req.Header.Set("MCC-Authentication", "MWSV2 "+app_id)
+	if _, err := testVerifier(mauthApp).VerifyRequest(req); err != ErrMalformedAuthentication {
+		t.Error("Expected ErrMalformedAuthentication, got ", err)
+	}
+}
+
+// Test a proxy normalizing the path doesn't break the V2 signature
+func TestMAuthVerifier_VerifyRequestNormalizedPath(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: true})
+	req, _ := mauthApp.makeRequest("GET", "https://innovate.mdsol.com//api/v2/./users/%e2%9c%93", "",
+		map[string][]string{})
+	req.URL, _ = req.URL.Parse("/api/v2/users/%E2%9C%93")
+	if _, err := testVerifier(mauthApp).VerifyRequest(req); err != nil {
+		t.Error("Request with normalized path did not verify: ", err)
+	}
+}
+
+// Test a Content-Length far larger than the body doesn't size the buffer it is read into
+func TestMAuthVerifier_VerifyRequestContentLength(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	req, _ := mauthApp.makeRequest("POST", "https://innovate.mdsol.com/api/v2/users.json", "ping",
+		map[string][]string{})
+	req.ContentLength = 1 << 40
+	if _, err := testVerifier(mauthApp).VerifyRequest(req); err != nil {
+		t.Error("Request did not verify: ", err)
+	}
+	body, _ := readBody(strings.NewReader("ping"), 1<<40)
+	if string(body) != "ping" || cap(body) > maxBodyPreallocation+bytes.MinRead {
+		t.Errorf("Unexpected body %q with capacity %d", body, cap(body))
+	}
+}
@@ -0,0 +1,103 @@
+package go_mauth_client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SignVersion is a version of the MAuth protocol used to sign requests
+type SignVersion string
+
+const (
+	// SignV1 is the MWS protocol, sent in the X-MWS-Authentication and X-MWS-Time headers
+	SignV1 SignVersion = "v1"
+	// SignV2 is the MWSV2 protocol, sent in the MCC-Authentication and MCC-Time headers
+	SignV2 SignVersion = "v2"
+)
+
+// SignVersionsEnv is the environment variable, shared with the other MAuth clients, holding the
+// comma separated versions to sign with, e.g. "v2" or "v1,v2"
+const SignVersionsEnv = "MAUTH_SIGN_VERSIONS"
+
+// ParseSignVersions parses a comma separated list of versions, such as "v1,v2"; the versions of
+// registered signature schemes are accepted too
+func ParseSignVersions(value string) ([]SignVersion, error) {
+	versions := []SignVersion{}
+	for _, part := range strings.Split(value, ",") {
+		version := SignVersion(strings.ToLower(strings.TrimSpace(part)))
+		if version == "" {
+			continue
+		}
+		if _, known := LookupSignatureScheme(version); !known {
+			return nil, fmt.Errorf("Unknown MAuth signing version %q", part)
+		}
+		if !hasSignVersion(versions, version) {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, errors.New("No MAuth signing versions given")
+	}
+	return versions, nil
+}
+
+// hasSignVersion checks whether version is in versions
+func hasSignVersion(versions []SignVersion, version SignVersion) bool {
+	for _, enabled := range versions {
+		if enabled == version {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSignVersions decides the versions for LoadMauth: the SignVersions option, then the DisableV1
+// option, then the MAUTH_SIGN_VERSIONS environment variable, and otherwise both versions
+func resolveSignVersions(options MAuthOptions) ([]SignVersion, error) {
+	if len(options.SignVersions) > 0 {
+		return ParseSignVersions(joinSignVersions(options.SignVersions))
+	}
+	if options.DisableV1 {
+		return []SignVersion{SignV2}, nil
+	}
+	if value, exists := os.LookupEnv(SignVersionsEnv); exists && strings.TrimSpace(value) != "" {
+		versions, err := ParseSignVersions(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", SignVersionsEnv, err)
+		}
+		return versions, nil
+	}
+	return []SignVersion{SignV1, SignV2}, nil
+}
+
+// joinSignVersions formats versions as a comma separated list
+func joinSignVersions(versions []SignVersion) string {
+	parts := []string{}
+	for _, version := range versions {
+		parts = append(parts, string(version))
+	}
+	return strings.Join(parts, ",")
+}
+
+// signsWith reports whether the App signs with version.  DisableV1 always turns V1 off, even
+// after SignVersions is set; an App built without SignVersions signs with V2 and, unless
+// disabled, V1.
+func (mauthApp *MAuthApp) signsWith(version SignVersion) bool {
+	if version == SignV1 && mauthApp.DisableV1 {
+		return false
+	}
+	if len(mauthApp.SignVersions) == 0 {
+		return version == SignV1 || version == SignV2
+	}
+	return hasSignVersion(mauthApp.SignVersions, version)
+}
+
+// withSignVersions returns a copy of the App which signs with versions
+func (mauthApp *MAuthApp) withSignVersions(versions []SignVersion) *MAuthApp {
+	app := *mauthApp
+	app.SignVersions = versions
+	app.DisableV1 = !hasSignVersion(versions, SignV1)
+	return &app
+}
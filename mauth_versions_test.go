@@ -0,0 +1,137 @@
+package go_mauth_client
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseSignVersions(t *testing.T) {
+	tests := map[string][]SignVersion{
+		"v1":        {SignV1},
+		"v2":        {SignV2},
+		"v1,v2":     {SignV1, SignV2},
+		" V2 , v1 ": {SignV2, SignV1},
+		"v2,v2":     {SignV2},
+	}
+	for value, expected := range tests {
+		versions, err := ParseSignVersions(value)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", value, err)
+		} else if !reflect.DeepEqual(versions, expected) {
+			t.Errorf("%q: expected %v, got %v", value, expected, versions)
+		}
+	}
+	for _, value := range []string{"", ",", "v3", "v1,mws"} {
+		if _, err := ParseSignVersions(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestLoadMauthSignVersions(t *testing.T) {
+	os.Unsetenv(SignVersionsEnv)
+	tests := []struct {
+		name     string
+		options  MAuthOptions
+		env      string
+		expected []SignVersion
+	}{
+		{"default", MAuthOptions{}, "", []SignVersion{SignV1, SignV2}},
+		{"DisableV1", MAuthOptions{DisableV1: true}, "", []SignVersion{SignV2}},
+		{"SignVersions", MAuthOptions{SignVersions: []SignVersion{SignV1}}, "", []SignVersion{SignV1}},
+		{"SignVersions over DisableV1", MAuthOptions{DisableV1: true, SignVersions: []SignVersion{SignV1, SignV2}}, "v2",
+			[]SignVersion{SignV1, SignV2}},
+		{"environment", MAuthOptions{}, "v2", []SignVersion{SignV2}},
+		{"DisableV1 over environment", MAuthOptions{DisableV1: true}, "v1,v2", []SignVersion{SignV2}},
+	}
+	for _, test := range tests {
+		if test.env != "" {
+			os.Setenv(SignVersionsEnv, test.env)
+		}
+		options := test.options
+		options.AppId = app_id
+		options.PrivateKey = "test/private_key.pem"
+		mauthApp, err := LoadMauth(options)
+		os.Unsetenv(SignVersionsEnv)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(mauthApp.SignVersions, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, mauthApp.SignVersions)
+		}
+		if mauthApp.DisableV1 == hasSignVersion(test.expected, SignV1) {
+			t.Errorf("%s: DisableV1 does not match the sign versions", test.name)
+		}
+	}
+}
+
+func TestLoadMauthSignVersionsInvalidEnvironment(t *testing.T) {
+	os.Setenv(SignVersionsEnv, "v3")
+	defer os.Unsetenv(SignVersionsEnv)
+	_, err := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: "test/private_key.pem"})
+	if err == nil {
+		t.Error("Expected an error for an unknown version in the environment")
+	}
+}
+
+func TestMakeAuthenticationHeadersSignVersions(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: "test/private_key.pem"})
+	target, _ := url.Parse("https://innovate.imedidata.com/api/v2/users.json")
+	tests := map[string]struct {
+		app    MAuthApp
+		v1, v2 bool
+	}{
+		"V1 only":          {MAuthApp{SignVersions: []SignVersion{SignV1}}, true, false},
+		"V2 only":          {MAuthApp{SignVersions: []SignVersion{SignV2}}, false, true},
+		"both":             {MAuthApp{SignVersions: []SignVersion{SignV1, SignV2}}, true, true},
+		"legacy":           {MAuthApp{}, true, true},
+		"legacy DisableV1": {MAuthApp{DisableV1: true}, false, true},
+		"DisableV1 over both": {MAuthApp{SignVersions: []SignVersion{SignV1, SignV2}, DisableV1: true}, false,
+			true},
+	}
+	for name, test := range tests {
+		app := test.app
+		app.AppId = mauthApp.AppId
+		app.RsaPrivateKey = mauthApp.RsaPrivateKey
+		headers, err := app.makeAuthenticationHeaders("GET", target, "", 1234567890)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+			continue
+		}
+		if _, signed := headers["X-MWS-Authentication"]; signed != test.v1 {
+			t.Errorf("%s: expected V1 headers %v", name, test.v1)
+		}
+		if _, signed := headers["MCC-Authentication"]; signed != test.v2 {
+			t.Errorf("%s: expected V2 headers %v", name, test.v2)
+		}
+	}
+}
+
+// Test setting DisableV1 on a loaded App turns off V1, which LoadMauth has put in SignVersions
+func TestDisableV1AfterLoadMauth(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: "test/private_key.pem"})
+	mauthApp.DisableV1 = true
+	req, _ := http.NewRequest("GET", "https://innovate.imedidata.com/api/v2/users.json", nil)
+	if err := mauthApp.SignRequest(req); err != nil {
+		t.Fatal("SignRequest Failed: ", err)
+	}
+	if hasMWSHeader(req) || !hasMCCHeader(req) {
+		t.Error("Expected only V2 headers on the signed request")
+	}
+	var sent *http.Request
+	transport := mauthApp.NewTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	req, _ = http.NewRequest("GET", "https://innovate.imedidata.com/api/v2/users.json", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal("RoundTrip Failed: ", err)
+	}
+	if hasMWSHeader(sent) || !hasMCCHeader(sent) {
+		t.Error("Expected only V2 headers from the transport")
+	}
+}
@@ -0,0 +1,96 @@
+package go_mauth_client
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+Signing and verification of WebSocket opening handshakes
+*/
+
+// ErrNotWebSocketHandshake is returned when verifying a request that doesn't ask for a WebSocket upgrade
+var ErrNotWebSocketHandshake = errors.New("Request is not a WebSocket handshake")
+
+// MakeWebSocketHeaders returns the signed MAuth headers for the handshake to a ws:// or wss:// URL.
+// WebSocket dialers build the handshake request themselves, so the headers are passed to them:
+//
+//	gorilla/websocket: websocket.DefaultDialer.Dial(rawurl, header)
+//	nhooyr.io/websocket: websocket.Dial(ctx, rawurl, &websocket.DialOptions{HTTPHeader: header})
+//
+// The Upgrade headers are left for the dialer to add, as duplicates are rejected.
+func (mauthApp *MAuthApp) MakeWebSocketHeaders(rawurl string) (http.Header, error) {
+	target, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	madeHeaders, err := mauthApp.makeAuthenticationHeaders("GET", target, "", mauthApp.epoch())
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	for name, value := range madeHeaders {
+		header.Set(name, value)
+	}
+	header.Set("User-Agent", strings.Join([]string{"go-mauth-client", GetVersion()}, "/"))
+	return header, nil
+}
+
+// MakeWebSocketRequest builds a complete, signed WebSocket handshake request, including the Upgrade
+// headers, for use where the handshake is sent with a plain http.Client
+func (mauthApp *MAuthApp) MakeWebSocketRequest(rawurl string) (*http.Request, error) {
+	header, err := mauthApp.MakeWebSocketHeaders(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	target, _ := url.Parse(rawurl)
+	// the handshake itself is sent over http(s)
+	switch target.Scheme {
+	case "ws":
+		target.Scheme = "http"
+	case "wss":
+		target.Scheme = "https"
+	}
+	req, err := http.NewRequest("GET", target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 16)
+	if _, err := io.ReadFull(mauthApp.random(), key); err != nil {
+		return nil, err
+	}
+	req.Header = header
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	return req, nil
+}
+
+// isWebSocketHandshake checks for a GET asking to upgrade the connection to a WebSocket
+func isWebSocketHandshake(req *http.Request) bool {
+	if req.Method != "GET" || !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range req.Header["Connection"] {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// VerifyWebSocketHandshake authenticates a WebSocket opening handshake, returning the App UUID that
+// signed it.  It should be called before the connection is upgraded.
+func (verifier *MAuthVerifier) VerifyWebSocketHandshake(req *http.Request) (appId string, err error) {
+	if !isWebSocketHandshake(req) {
+		return "", ErrNotWebSocketHandshake
+	}
+	return verifier.VerifyRequest(req)
+}
@@ -0,0 +1,107 @@
+package go_mauth_client
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMAuthApp_MakeWebSocketHeaders(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	header, err := mauthApp.MakeWebSocketHeaders("wss://dashboard.mdsol.com/studies/feed?site=101")
+	if err != nil {
+		t.Error("Error making headers: ", err)
+	}
+	if header.Get("MCC-Authentication") == "" || header.Get("X-MWS-Authentication") == "" {
+		t.Error("Expected MAuth headers not present")
+	}
+	if header.Get("Upgrade") != "" {
+		t.Error("Upgrade header should be left to the dialer")
+	}
+	// the dialer sends the same path and query on the handshake
+	req, _ := http.NewRequest("GET", "https://dashboard.mdsol.com/studies/feed?site=101", nil)
+	req.Header = header
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	if _, err := testVerifier(mauthApp).VerifyWebSocketHandshake(req); err != nil {
+		t.Error("Handshake did not verify: ", err)
+	}
+	_, err = mauthApp.MakeWebSocketHeaders("wss://\x7fdashboard.mdsol.com")
+	if err == nil {
+		t.Error("Expected error with Bad URL")
+	}
+}
+
+func TestMAuthApp_MakeWebSocketRequest(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: true})
+	req, err := mauthApp.MakeWebSocketRequest("ws://dashboard.mdsol.com/studies/feed")
+	if err != nil {
+		t.Error("Error making request: ", err)
+	}
+	if req.URL.Scheme != "http" {
+		t.Error("Expected handshake to be sent over http, got ", req.URL.Scheme)
+	}
+	if req.Header.Get("Sec-WebSocket-Key") == "" || req.Header.Get("Upgrade") != "websocket" {
+		t.Error("Expected Upgrade headers not present")
+	}
+	if hasMWSHeader(req) {
+		t.Error("Unexpected MWS header present")
+	}
+	if _, err := testVerifier(mauthApp).VerifyWebSocketHandshake(req); err != nil {
+		t.Error("Handshake did not verify: ", err)
+	}
+}
+
+func TestMAuthVerifier_VerifyWebSocketHandshakeNotUpgrade(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	req, _ := mauthApp.makeRequest("GET", "https://dashboard.mdsol.com/studies/feed", "",
+		map[string][]string{})
+	if _, err := testVerifier(mauthApp).VerifyWebSocketHandshake(req); err != ErrNotWebSocketHandshake {
+		t.Error("Expected ErrNotWebSocketHandshake, got ", err)
+	}
+}
+
+// Test the handshake is authenticated by a server before it switches protocols
+func TestMAuthVerifier_VerifyWebSocketHandshakeServer(t *testing.T) {
+	mauthApp, _ := LoadMauth(MAuthOptions{AppId: app_id, PrivateKey: filepath.Join("test", "private_key.pem"), DisableV1: false})
+	verifier := testVerifier(mauthApp)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifier.VerifyWebSocketHandshake(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Upgrade", "websocket")
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/studies/feed"
+
+	for _, signed := range []bool{true, false} {
+		req, _ := mauthApp.MakeWebSocketRequest(wsURL)
+		if !signed {
+			req.Header.Del("MCC-Authentication")
+			req.Header.Del("X-MWS-Authentication")
+		}
+		conn, err := net.Dial("tcp", req.URL.Host)
+		if err != nil {
+			t.Fatal("Unable to connect: ", err)
+		}
+		_ = req.Write(conn)
+		response, err := http.ReadResponse(bufio.NewReader(conn), req)
+		_ = conn.Close()
+		if err != nil {
+			t.Fatal("Unable to read response: ", err)
+		}
+		if signed && response.StatusCode != http.StatusSwitchingProtocols {
+			t.Error("Expected signed handshake to be upgraded, got ", response.StatusCode)
+		}
+		if !signed && response.StatusCode != http.StatusUnauthorized {
+			t.Error("Expected unsigned handshake to be rejected, got ", response.StatusCode)
+		}
+	}
+}
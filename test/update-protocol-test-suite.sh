@@ -0,0 +1,30 @@
+#!/bin/sh
+# Vendors the shared MAuth protocol test suite into test/mauth-protocol-test-suite, with its license,
+# for mauth_signing_cases_test.go.  Pass the commit or tag to vendor; commit the result.
+set -eu
+
+if [ $# -ne 1 ]; then
+  echo "usage: $0 <commit-or-tag>" >&2
+  exit 2
+fi
+ref=$1
+repository=${MAUTH_PROTOCOL_TEST_SUITE_REPOSITORY:-https://github.com/mdsol/mauth-protocol-test-suite.git}
+target=$(cd "$(dirname "$0")" && pwd)/mauth-protocol-test-suite
+checkout=$(mktemp -d)
+trap 'rm -rf "$checkout"' EXIT
+
+git clone --quiet "$repository" "$checkout"
+git -C "$checkout" checkout --quiet "$ref"
+if [ ! -f "$checkout/LICENSE" ]; then
+  echo "the suite at $ref has no LICENSE, not vendoring it" >&2
+  exit 1
+fi
+
+rm -rf "$target"
+mkdir -p "$target"
+cp -R "$checkout/LICENSE" "$checkout/signing-config.json" "$checkout/signing-params" "$checkout/protocols" "$target/"
+if [ -d "$checkout/resources" ]; then
+  cp -R "$checkout/resources" "$target/"
+fi
+echo "$repository $(git -C "$checkout" rev-parse HEAD)" > "$target/VERSION"
+echo "vendored $(cat "$target/VERSION") into $target"